@@ -0,0 +1,239 @@
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuki5155/go-llms/anthropic-llm/anthropic"
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// newTestServer はリクエストボディを検査して固定のレスポンスを返すMessages APIの代替サーバーを作成します
+func newTestServer(t *testing.T, check func(body map[string]any), response string) *anthropic.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("missing authentication headers: %v", r.Header)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		check(body)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+
+	config := anthropic.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	return anthropic.NewClient(config)
+}
+
+func TestSendRequestWithFunctionCall(t *testing.T) {
+	client := newTestServer(t, func(body map[string]any) {
+		if body["system"] != "You are a helpful assistant." {
+			t.Errorf("expected system prompt to be extracted, got %v", body["system"])
+		}
+		messages := body["messages"].([]any)
+		if len(messages) != 3 {
+			t.Errorf("expected 3 messages, got %d: %v", len(messages), messages)
+			return
+		}
+
+		first := messages[0].(map[string]any)["content"].([]any)
+		image := first[0].(map[string]any)
+		source := image["source"].(map[string]any)
+		if image["type"] != "image" || source["type"] != "base64" || source["media_type"] != "image/jpeg" || source["data"] != "AQID" {
+			t.Errorf("unexpected image block: %v", image)
+		}
+
+		toolUse := messages[1].(map[string]any)["content"].([]any)[0].(map[string]any)
+		if toolUse["type"] != "tool_use" || toolUse["id"] != "toolu_1" || toolUse["input"].(map[string]any)["location"] != "Tokyo" {
+			t.Errorf("unexpected tool_use block: %v", toolUse)
+		}
+
+		last := messages[2].(map[string]any)
+		result := last["content"].([]any)
+		if last["role"] != "user" || len(result) != 2 {
+			t.Errorf("expected merged user message with tool result and text, got %v", last)
+			return
+		}
+		if block := result[0].(map[string]any); block["type"] != "tool_result" || block["tool_use_id"] != "toolu_1" {
+			t.Errorf("unexpected tool_result block: %v", block)
+		}
+
+		tools := body["tools"].([]any)
+		if tool := tools[0].(map[string]any); tool["name"] != "weather" || tool["input_schema"] == nil {
+			t.Errorf("unexpected tool: %v", tool)
+		}
+		choice := body["tool_choice"].(map[string]any)
+		if choice["type"] != "any" || choice["disable_parallel_tool_use"] != true {
+			t.Errorf("unexpected tool_choice: %v", choice)
+		}
+	}, `{
+		"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-test",
+		"content": [
+			{"type": "text", "text": "Let me check."},
+			{"type": "tool_use", "id": "toolu_2", "name": "weather", "input": {"location": "Osaka"}}
+		],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 20, "output_tokens": 10, "cache_read_input_tokens": 5, "cache_creation_input_tokens": 8}
+	}`)
+
+	toolsJSON, _ := json.Marshal([]schema.Tool{*schema.NewWeatherFunctionCallSchema()})
+	assistant := utils.Message{
+		Role: utils.RoleAssistant,
+		ToolCalls: []utils.ToolCall{{
+			ID:       "toolu_1",
+			Type:     "function",
+			Function: utils.Function{Name: "weather", Arguments: `{"location":"Tokyo"}`},
+		}},
+	}
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{
+			utils.NewMessage(utils.RoleSystem, "You are a helpful assistant."),
+			utils.NewMessageWithImageBase64([]byte{1, 2, 3}, "What is this?"),
+			assistant,
+			utils.NewToolMessage("toolu_1", `{"temperature":20}`),
+			utils.NewMessage(utils.RoleUser, "And Osaka?"),
+		},
+		Schema: toolsJSON,
+		Parameters: &utils.Parameters{
+			ToolChoice:        utils.ToolChoiceRequired(),
+			ParallelToolCalls: utils.Ptr(false),
+		},
+	}
+
+	res, err := client.SendRequestWithFunctionCall(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	call, err := res.GetFunctionCall("weather")
+	if err != nil {
+		t.Fatalf("expected tool call: %v", err)
+	}
+	if call.ID != "toolu_2" || call.Function.Arguments != `{"location": "Osaka"}` {
+		t.Errorf("unexpected tool call: %+v", call)
+	}
+	if res.Choices[0].FinishReason != "tool_calls" || res.Choices[0].Message.Text() != "Let me check." {
+		t.Errorf("unexpected choice: %+v", res.Choices[0])
+	}
+	details := res.Usage.PromptTokensDetails
+	if res.Usage.PromptTokens != 33 || details.CachedTokens != 5 || details.CacheWriteTokens != 8 || res.Usage.TotalTokens != 43 {
+		t.Errorf("unexpected usage: %+v", res.Usage)
+	}
+}
+
+func TestSendRequestWithStructuredOutput(t *testing.T) {
+	client := newTestServer(t, func(body map[string]any) {
+		choice := body["tool_choice"].(map[string]any)
+		if choice["type"] != "tool" || choice["name"] != "weather_response" {
+			t.Errorf("expected forced tool choice, got %v", choice)
+		}
+		tool := body["tools"].([]any)[0].(map[string]any)
+		if tool["input_schema"].(map[string]any)["type"] != "object" {
+			t.Errorf("expected schema as input_schema, got %v", tool)
+		}
+	}, `{
+		"id": "msg_2", "type": "message", "role": "assistant", "model": "claude-test",
+		"content": [{"type": "tool_use", "id": "toolu_1", "name": "weather_response",
+			"input": {"location": "Tokyo", "temperature": 22, "unit": "C", "conditions": "Clear"}}],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 30, "output_tokens": 12}
+	}`)
+
+	schemaJSON, _ := json.Marshal(schema.NewWeatherSchema())
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "What's the weather like in Tokyo today?")},
+		Schema:   schemaJSON,
+	}
+	res, err := client.SendRequestWithStructuredOutput(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	weather, err := utils.HandleResponse[schema.WeatherResponse](res)
+	if err != nil {
+		t.Fatalf("unexpected error handling response: %v", err)
+	}
+	if weather.Location != "Tokyo" || weather.Temperature != 22 || weather.Unit != "C" {
+		t.Errorf("unexpected weather: %+v", weather)
+	}
+	if res.ID != "msg_2" || res.Model != "claude-test" || res.Usage.PromptTokens != 30 || res.Usage.CompletionTokens != 12 {
+		t.Errorf("expected response metadata and usage, got id=%q model=%q usage=%+v", res.ID, res.Model, res.Usage)
+	}
+}
+
+func TestSendRequestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("request-id", "req_ant")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has exceeded your rate limit"}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	config := anthropic.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	_, err := anthropic.NewClient(config).SendRequestWithFunctionCall(context.Background(), utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	})
+
+	var apiErr *utils.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *utils.APIError, got %T: %v", err, err)
+	}
+	if apiErr.Type != "rate_limit_error" || apiErr.RequestID != "req_ant" || !utils.IsRateLimited(err) {
+		t.Errorf("unexpected api error: %+v", apiErr)
+	}
+}
+
+func TestSendRequestContextErrors(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	config := anthropic.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	client := anthropic.NewClient(config)
+	opts := utils.RequestOptions{Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.SendRequestWithFunctionCall(ctx, opts); !errors.Is(err, utils.ErrRequestTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected ErrRequestTimeout, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := client.SendRequestWithFunctionCall(ctx, opts); !errors.Is(err, utils.ErrRequestCanceled) {
+		t.Errorf("expected ErrRequestCanceled, got %v", err)
+	}
+}
+
+func TestSendRequestRejectsTemperatureAboveOne(t *testing.T) {
+	client := newTestServer(t, func(body map[string]any) {
+		t.Error("expected the request not to be sent")
+	}, `{}`)
+
+	temperature := 1.5
+	_, err := client.SendRequestWithFunctionCall(context.Background(), utils.RequestOptions{
+		Messages:   []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+		Parameters: &utils.Parameters{Temperature: &temperature},
+	})
+	if err == nil || !strings.Contains(err.Error(), "between 0 and 1") {
+		t.Errorf("expected a temperature range error, got %v", err)
+	}
+}
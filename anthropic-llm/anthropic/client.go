@@ -0,0 +1,224 @@
+// Package anthropic はAnthropic Messages APIのクライアントを提供します
+// メッセージとスキーマは openai-llm の型をそのまま利用できます
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+const (
+	DefaultAPIEndpoint = "https://api.anthropic.com/v1/messages"
+	DefaultModel       = "claude-sonnet-4-5"
+	DefaultAPIVersion  = "2023-06-01"
+	DefaultMaxTokens   = 4096
+)
+
+type ClientConfig struct {
+	APIKey   string
+	Endpoint string
+	Model    string
+	// Version は anthropic-version ヘッダーの値です
+	Version string
+	// MaxTokens は生成する最大トークン数です（Messages APIでは必須）
+	MaxTokens int
+	Client    *http.Client
+	// DefaultParameters は全てのリクエストに適用する生成パラメーターです
+	DefaultParameters *utils.Parameters
+	// CostSettings はコストを集計する utils.CostTracker とクライアントの名前です
+	utils.CostSettings
+}
+
+func NewClientConfig(apiKey string) *ClientConfig {
+	return &ClientConfig{
+		APIKey:    apiKey,
+		Endpoint:  DefaultAPIEndpoint,
+		Model:     DefaultModel,
+		Version:   DefaultAPIVersion,
+		MaxTokens: DefaultMaxTokens,
+		Client:    &http.Client{},
+	}
+}
+
+type Client struct {
+	config *ClientConfig
+}
+
+func NewClient(config *ClientConfig) *Client {
+	return &Client{config: config}
+}
+
+// SendRequestWithFunctionCall はメッセージとツールを送信し、OpenAI形式のChatCompletionに変換して返します
+// opts.Schema には schema.Tool の配列をJSONで指定します
+func (c *Client) SendRequestWithFunctionCall(ctx context.Context, opts utils.RequestOptions) (*utils.ChatCompletion, error) {
+	reqBody, params, err := c.requestBody(opts)
+	if err != nil {
+		return nil, err
+	}
+	if reqBody.Tools, err = convertTools(opts.Schema); err != nil {
+		return nil, err
+	}
+	if len(reqBody.Tools) == 0 {
+		params = params.WithoutToolDefaults(opts.Parameters)
+	}
+	if reqBody.ToolChoice, err = convertToolChoice(params, reqBody.Tools); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	return toChatCompletion(resp), nil
+}
+
+// SendRequestWithStructuredOutput はスキーマを持つツールの呼び出しを強制することで構造化出力を取得します
+// opts.Schema には schema.WeatherSchema のような name と schema を持つJSONを指定します
+// 戻り値は utils.HandleResponse でパースできます
+func (c *Client) SendRequestWithStructuredOutput(ctx context.Context, opts utils.RequestOptions) (*utils.APIResponse, error) {
+	var format struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Schema      json.RawMessage `json:"schema"`
+	}
+	if err := json.Unmarshal(opts.Schema, &format); err != nil {
+		return nil, fmt.Errorf("error parsing schema: %v", err)
+	}
+	if format.Name == "" || len(format.Schema) == 0 {
+		return nil, fmt.Errorf("schema must have a name and a schema")
+	}
+	description := format.Description
+	if description == "" {
+		description = "Respond with structured output that matches the input schema."
+	}
+
+	reqBody, _, err := c.requestBody(opts)
+	if err != nil {
+		return nil, err
+	}
+	reqBody.Tools = []tool{{Name: format.Name, Description: description, InputSchema: format.Schema}}
+	reqBody.ToolChoice = &toolChoice{Type: "tool", Name: format.Name}
+
+	resp, err := c.send(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var choice utils.ResponseChoice
+	choice.Message.Role = "assistant"
+	choice.FinishReason = finishReason(resp.StopReason)
+	for _, block := range resp.Content {
+		if block.Type == "tool_use" && block.Name == format.Name {
+			choice.Message.Content, _ = json.Marshal(string(block.Input))
+			// 強制したツール呼び出しによる終了は通常の完了として扱う
+			if choice.FinishReason == "tool_calls" {
+				choice.FinishReason = "stop"
+			}
+			break
+		}
+	}
+	return &utils.APIResponse{
+		ID:      resp.ID,
+		Model:   resp.Model,
+		Object:  "chat.completion",
+		Choices: []utils.ResponseChoice{choice},
+		Usage:   convertUsage(resp.Usage),
+	}, nil
+}
+
+// requestBody はメッセージと生成パラメーターを変換してリクエストボディを作成します
+// Messages APIに対応する項目がないパラメーターは無視され、マージ後の生成パラメーターも返します
+func (c *Client) requestBody(opts utils.RequestOptions) (*messagesRequest, *utils.Parameters, error) {
+	if len(opts.Messages) == 0 {
+		return nil, nil, fmt.Errorf("at least one message is required")
+	}
+	params := c.config.DefaultParameters.Merge(opts.Parameters)
+	if err := params.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid request: %w", err)
+	}
+	// Messages APIの temperature は共通の検証（0〜2）より狭い 0〜1 です
+	if params != nil && params.Temperature != nil && *params.Temperature > 1 {
+		return nil, nil, fmt.Errorf("invalid request: temperature must be between 0 and 1, got %v", *params.Temperature)
+	}
+	system, converted, err := convertMessages(opts.Messages)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(converted) == 0 {
+		return nil, nil, fmt.Errorf("at least one non-system message is required")
+	}
+
+	maxTokens := c.config.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+	reqBody := &messagesRequest{
+		Model:     c.config.Model,
+		MaxTokens: maxTokens,
+		System:    system,
+		Messages:  converted,
+	}
+	if params != nil {
+		if params.MaxCompletionTokens != nil {
+			reqBody.MaxTokens = *params.MaxCompletionTokens
+		}
+		reqBody.Temperature = params.Temperature
+		reqBody.TopP = params.TopP
+		reqBody.StopSequences = params.Stop
+		if params.User != "" {
+			reqBody.Metadata = &requestMetadata{UserID: params.User}
+		}
+	}
+	return reqBody, params, nil
+}
+
+// send はリクエストを送信し、レスポンスをデコードします
+func (c *Client) send(ctx context.Context, reqBody *messagesRequest) (*messagesResponse, error) {
+	if err := c.config.CostSettings.Allow(ctx, c.config.Model); err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.Endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	version := c.config.Version
+	if version == "" {
+		version = DefaultAPIVersion
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.config.APIKey)
+	req.Header.Set("anthropic-version", version)
+
+	resp, err := c.config.Client.Do(req)
+	if err != nil {
+		return nil, utils.WrapRequestError(ctx, fmt.Errorf("error sending request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, utils.WrapRequestError(ctx, fmt.Errorf("error reading response: %w", err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, utils.NewAPIError(resp, body)
+	}
+
+	var parsed messagesResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	c.config.CostSettings.Record(ctx, c.config.Model, parsed.Model, convertUsage(parsed.Usage))
+	return &parsed, nil
+}
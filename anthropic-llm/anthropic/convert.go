@@ -0,0 +1,264 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// convertMessages は utils.Message をMessages APIのシステムプロンプトとメッセージに変換します
+// system ロールは system フィールドに、tool ロールは user ロールの tool_result ブロックに変換され、
+// 同じロールが連続する場合は1つのメッセージにまとめられます
+func convertMessages(messages []utils.Message) (string, []message, error) {
+	var system []string
+	var converted []message
+
+	for i, msg := range messages {
+		var role string
+		var blocks []contentBlock
+
+		switch msg.Role {
+		case utils.RoleSystem:
+			text, err := messageText(msg.Content)
+			if err != nil {
+				return "", nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			system = append(system, text)
+			continue
+
+		case utils.RoleTool:
+			text, err := messageText(msg.Content)
+			if err != nil {
+				return "", nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			role = "user"
+			blocks = []contentBlock{{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: text}}
+
+		case utils.RoleUser, utils.RoleAssistant:
+			var err error
+			role = string(msg.Role)
+			blocks, err = contentBlocks(msg.Content)
+			if err != nil {
+				return "", nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, contentBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+			}
+
+		default:
+			return "", nil, fmt.Errorf("message %d: unsupported role %q", i, msg.Role)
+		}
+
+		if len(blocks) == 0 {
+			continue
+		}
+		if last := len(converted) - 1; last >= 0 && converted[last].Role == role {
+			converted[last].Content = append(converted[last].Content, blocks...)
+			continue
+		}
+		converted = append(converted, message{Role: role, Content: blocks})
+	}
+
+	return strings.Join(system, "\n\n"), converted, nil
+}
+
+// messageText はメッセージの内容をテキストとして取り出します
+func messageText(content json.RawMessage) (string, error) {
+	blocks, err := contentBlocks(content)
+	if err != nil {
+		return "", err
+	}
+	var texts []string
+	for _, block := range blocks {
+		if block.Type != "text" {
+			return "", fmt.Errorf("only text content is supported here, got %s", block.Type)
+		}
+		texts = append(texts, block.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// contentBlocks は文字列または utils.Content の配列であるメッセージ内容をブロックに変換します
+func contentBlocks(content json.RawMessage) ([]contentBlock, error) {
+	if len(content) == 0 || string(content) == "null" {
+		return nil, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		if text == "" {
+			return nil, nil
+		}
+		return []contentBlock{{Type: "text", Text: text}}, nil
+	}
+
+	var parts []utils.Content
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return nil, fmt.Errorf("unsupported message content: %v", err)
+	}
+
+	blocks := make([]contentBlock, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			blocks = append(blocks, contentBlock{Type: "text", Text: part.Text})
+		case "image_url":
+			if part.ImageUrl == nil {
+				return nil, fmt.Errorf("image_url content without url")
+			}
+			source, err := convertImage(part.ImageUrl.Url)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, contentBlock{Type: "image", Source: source})
+		default:
+			return nil, fmt.Errorf("unsupported content type %q", part.Type)
+		}
+	}
+	return blocks, nil
+}
+
+// convertImage は画像URL（data URL を含む）を画像ソースに変換します
+func convertImage(url string) (*imageSource, error) {
+	if !strings.HasPrefix(url, "data:") {
+		return &imageSource{Type: "url", URL: url}, nil
+	}
+
+	meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	mediaType, isBase64 := strings.CutSuffix(meta, ";base64")
+	if !ok || !isBase64 {
+		return nil, fmt.Errorf("unsupported data URL: only base64 encoded images are supported")
+	}
+	return &imageSource{Type: "base64", MediaType: mediaType, Data: data}, nil
+}
+
+// convertTools は RequestOptions.Schema に指定されたツール定義を変換します
+func convertTools(toolsJSON json.RawMessage) ([]tool, error) {
+	if len(toolsJSON) == 0 {
+		return nil, nil
+	}
+
+	var tools []schema.Tool
+	if err := json.Unmarshal(toolsJSON, &tools); err != nil {
+		return nil, fmt.Errorf("error parsing tools: %v", err)
+	}
+
+	converted := make([]tool, 0, len(tools))
+	for _, t := range tools {
+		inputSchema, err := json.Marshal(t.Function.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling parameters of tool %s: %v", t.Function.Name, err)
+		}
+		converted = append(converted, tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: inputSchema,
+		})
+	}
+	return converted, nil
+}
+
+// convertToolChoice は tool_choice と parallel_tool_calls をMessages APIの tool_choice に変換します
+func convertToolChoice(params *utils.Parameters, tools []tool) (*toolChoice, error) {
+	if params == nil || (params.ToolChoice == nil && params.ParallelToolCalls == nil) {
+		return nil, nil
+	}
+	names := make([]string, 0, len(tools))
+	for _, t := range tools {
+		names = append(names, t.Name)
+	}
+
+	choice := &toolChoice{Type: "auto"}
+	if params.ToolChoice != nil {
+		if err := params.ToolChoice.ValidateTools(names); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+		switch {
+		case params.ToolChoice.Function != "":
+			choice = &toolChoice{Type: "tool", Name: params.ToolChoice.Function}
+		case params.ToolChoice.Mode == "required":
+			choice = &toolChoice{Type: "any"}
+		case params.ToolChoice.Mode == "none":
+			choice = &toolChoice{Type: "none"}
+		}
+	} else if len(tools) == 0 {
+		return nil, fmt.Errorf("invalid request: parallel_tool_calls requires tools")
+	}
+	if params.ParallelToolCalls != nil && choice.Type != "none" {
+		choice.DisableParallelToolUse = !*params.ParallelToolCalls
+	}
+	return choice, nil
+}
+
+// finishReason はstop_reasonをOpenAI形式のfinish_reasonに変換します
+func finishReason(stopReason string) string {
+	switch stopReason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return stopReason
+	}
+}
+
+// convertUsage はMessages APIのトークン使用量を utils.Usage に変換します
+func convertUsage(u usage) utils.Usage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return utils.Usage{
+		PromptTokens: prompt,
+		PromptTokensDetails: utils.PromptTokenDetails{
+			CachedTokens:     u.CacheReadInputTokens,
+			CacheWriteTokens: u.CacheCreationInputTokens,
+		},
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+	}
+}
+
+// toChatCompletion はレスポンスを utils.ChatCompletion に変換します
+func toChatCompletion(resp *messagesResponse) *utils.ChatCompletion {
+	msg := utils.ChatMessage{Role: "assistant"}
+	var texts []string
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			texts = append(texts, block.Text)
+		case "tool_use":
+			msg.ToolCalls = append(msg.ToolCalls, utils.ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: utils.Function{
+					Name:      block.Name,
+					Arguments: string(block.Input),
+				},
+			})
+		}
+	}
+	if len(texts) > 0 {
+		msg.Content, _ = json.Marshal(strings.Join(texts, ""))
+	}
+
+	return &utils.ChatCompletion{
+		ID:     resp.ID,
+		Model:  resp.Model,
+		Object: "chat.completion",
+		Choices: []utils.Choice{{
+			FinishReason: finishReason(resp.StopReason),
+			Index:        0,
+			Message:      msg,
+		}},
+		Usage: convertUsage(resp.Usage),
+	}
+}
@@ -0,0 +1,204 @@
+// Package gemini はGoogle Gemini API (generateContent) のクライアントを提供します
+// メッセージとスキーマは openai-llm の型をそのまま利用できます
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+const (
+	DefaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	DefaultModel   = "gemini-2.5-flash"
+)
+
+type ClientConfig struct {
+	APIKey string
+	// BaseURL はAPIのベースURLです（リクエストは {BaseURL}/models/{Model}:generateContent に送信されます）
+	BaseURL string
+	Model   string
+	// MaxOutputTokens は生成する最大トークン数です（0の場合はAPIの既定値）
+	MaxOutputTokens int
+	Client          *http.Client
+	// DefaultParameters は全てのリクエストに適用する生成パラメーターです
+	DefaultParameters *utils.Parameters
+	// CostSettings はコストを集計する utils.CostTracker とクライアントの名前です
+	utils.CostSettings
+}
+
+func NewClientConfig(apiKey string) *ClientConfig {
+	return &ClientConfig{
+		APIKey:  apiKey,
+		BaseURL: DefaultBaseURL,
+		Model:   DefaultModel,
+		Client:  &http.Client{},
+	}
+}
+
+type Client struct {
+	config *ClientConfig
+}
+
+func NewClient(config *ClientConfig) *Client {
+	return &Client{config: config}
+}
+
+// SendRequestWithFunctionCall はメッセージとツールを送信し、OpenAI形式のChatCompletionに変換して返します
+// opts.Schema には schema.Tool の配列をJSONで指定します
+func (c *Client) SendRequestWithFunctionCall(ctx context.Context, opts utils.RequestOptions) (*utils.ChatCompletion, error) {
+	reqBody, params, err := c.requestBody(opts)
+	if err != nil {
+		return nil, err
+	}
+	if reqBody.Tools, err = convertTools(opts.Schema); err != nil {
+		return nil, err
+	}
+	if len(reqBody.Tools) == 0 {
+		params = params.WithoutToolDefaults(opts.Parameters)
+	}
+	if params != nil && params.ToolChoice != nil {
+		if reqBody.ToolConfig, err = convertToolChoice(params.ToolChoice, reqBody.Tools); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.send(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	return toChatCompletion(resp), nil
+}
+
+// SendRequestWithStructuredOutput はスキーマを responseSchema に変換して構造化出力を取得します
+// opts.Schema には schema.WeatherSchema のような name と schema を持つJSONを指定します
+// 戻り値は utils.HandleResponse でパースできます
+func (c *Client) SendRequestWithStructuredOutput(ctx context.Context, opts utils.RequestOptions) (*utils.APIResponse, error) {
+	var format struct {
+		Name   string             `json:"name"`
+		Schema *schema.BaseSchema `json:"schema"`
+	}
+	if err := json.Unmarshal(opts.Schema, &format); err != nil {
+		return nil, fmt.Errorf("error parsing schema: %v", err)
+	}
+	if format.Schema == nil {
+		return nil, fmt.Errorf("schema must have a schema")
+	}
+	responseSchema, err := ConvertSchema(*format.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("error converting schema %s: %v", format.Name, err)
+	}
+
+	reqBody, _, err := c.requestBody(opts)
+	if err != nil {
+		return nil, err
+	}
+	reqBody.GenerationConfig.ResponseMimeType = "application/json"
+	reqBody.GenerationConfig.ResponseSchema = responseSchema
+
+	resp, err := c.send(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return toChatCompletion(resp), nil
+}
+
+// requestBody はメッセージと生成パラメーターを変換してリクエストボディを作成します
+// generationConfig に対応する項目がないパラメーターは無視され、マージ後の生成パラメーターも返します
+func (c *Client) requestBody(opts utils.RequestOptions) (*generateContentRequest, *utils.Parameters, error) {
+	if len(opts.Messages) == 0 {
+		return nil, nil, fmt.Errorf("at least one message is required")
+	}
+	params := c.config.DefaultParameters.Merge(opts.Parameters)
+	if err := params.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid request: %w", err)
+	}
+	system, converted, err := convertMessages(opts.Messages)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(converted) == 0 {
+		return nil, nil, fmt.Errorf("at least one non-system message is required")
+	}
+
+	config := &generationConfig{MaxOutputTokens: c.config.MaxOutputTokens}
+	if params != nil {
+		if params.MaxCompletionTokens != nil {
+			config.MaxOutputTokens = *params.MaxCompletionTokens
+		}
+		config.Temperature = params.Temperature
+		config.TopP = params.TopP
+		config.CandidateCount = params.N
+		config.StopSequences = params.Stop
+		config.Seed = params.Seed
+		config.PresencePenalty = params.PresencePenalty
+		config.FrequencyPenalty = params.FrequencyPenalty
+	}
+	return &generateContentRequest{
+		Contents:          converted,
+		SystemInstruction: system,
+		GenerationConfig:  config,
+	}, params, nil
+}
+
+// endpoint は generateContent のURLを返します
+func (c *Client) endpoint() string {
+	baseURL := c.config.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	model := c.config.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	model = strings.TrimPrefix(model, "models/")
+	return strings.TrimRight(baseURL, "/") + "/models/" + url.PathEscape(model) + ":generateContent"
+}
+
+// send はリクエストを送信し、レスポンスをデコードします
+func (c *Client) send(ctx context.Context, reqBody *generateContentRequest) (*generateContentResponse, error) {
+	if err := c.config.CostSettings.Allow(ctx, c.config.Model); err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(), bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", c.config.APIKey)
+
+	resp, err := c.config.Client.Do(req)
+	if err != nil {
+		return nil, utils.WrapRequestError(ctx, fmt.Errorf("error sending request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, utils.WrapRequestError(ctx, fmt.Errorf("error reading response: %w", err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, utils.NewAPIError(resp, body)
+	}
+
+	var parsed generateContentResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	c.config.CostSettings.Record(ctx, c.config.Model, parsed.ModelVersion, convertUsage(parsed.UsageMetadata))
+	return &parsed, nil
+}
@@ -0,0 +1,328 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// convertMessages は utils.Message をGeminiのシステム指示とコンテンツに変換します
+// system ロールは systemInstruction に、assistant ロールは model ロールに、
+// tool ロールは user ロールの functionResponse パートに変換され、
+// 同じロールが連続する場合は1つのコンテンツにまとめられます
+func convertMessages(messages []utils.Message) (*content, []content, error) {
+	var system []part
+	var converted []content
+	// toolNames はツール呼び出しIDから関数名を引くためのマップです（functionResponse には関数名が必要）
+	toolNames := make(map[string]string)
+
+	for i, msg := range messages {
+		var role string
+		var parts []part
+
+		switch msg.Role {
+		case utils.RoleSystem:
+			text, err := messageText(msg.Content)
+			if err != nil {
+				return nil, nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			system = append(system, part{Text: text})
+			continue
+
+		case utils.RoleTool:
+			name, ok := toolNames[msg.ToolCallID]
+			if !ok {
+				return nil, nil, fmt.Errorf("message %d: unknown tool call id %q", i, msg.ToolCallID)
+			}
+			text, err := messageText(msg.Content)
+			if err != nil {
+				return nil, nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			role = "user"
+			parts = []part{{FunctionResponse: &functionResponse{
+				ID:       msg.ToolCallID,
+				Name:     name,
+				Response: toolResponse(text),
+			}}}
+
+		case utils.RoleUser, utils.RoleAssistant:
+			var err error
+			role = "user"
+			if msg.Role == utils.RoleAssistant {
+				role = "model"
+			}
+			parts, err = contentParts(msg.Content)
+			if err != nil {
+				return nil, nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			for _, call := range msg.ToolCalls {
+				args := json.RawMessage(call.Function.Arguments)
+				if len(args) == 0 {
+					args = json.RawMessage("{}")
+				}
+				toolNames[call.ID] = call.Function.Name
+				parts = append(parts, part{FunctionCall: &functionCall{ID: call.ID, Name: call.Function.Name, Args: args}})
+			}
+
+		default:
+			return nil, nil, fmt.Errorf("message %d: unsupported role %q", i, msg.Role)
+		}
+
+		if len(parts) == 0 {
+			continue
+		}
+		if last := len(converted) - 1; last >= 0 && converted[last].Role == role {
+			converted[last].Parts = append(converted[last].Parts, parts...)
+			continue
+		}
+		converted = append(converted, content{Role: role, Parts: parts})
+	}
+
+	if len(system) == 0 {
+		return nil, converted, nil
+	}
+	return &content{Parts: system}, converted, nil
+}
+
+// toolResponse はツールの結果を functionResponse.response に変換します
+// response はオブジェクトである必要があるため、それ以外の結果は result フィールドに格納します
+func toolResponse(text string) json.RawMessage {
+	trimmed := strings.TrimSpace(text)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed)
+	}
+	var value any = text
+	if json.Valid([]byte(trimmed)) {
+		value = json.RawMessage(trimmed)
+	}
+	wrapped, _ := json.Marshal(map[string]any{"result": value})
+	return wrapped
+}
+
+// messageText はメッセージの内容をテキストとして取り出します
+func messageText(raw json.RawMessage) (string, error) {
+	parts, err := contentParts(raw)
+	if err != nil {
+		return "", err
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.InlineData != nil || p.FileData != nil {
+			return "", fmt.Errorf("only text content is supported here")
+		}
+		texts = append(texts, p.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// contentParts は文字列または utils.Content の配列であるメッセージ内容をパートに変換します
+func contentParts(raw json.RawMessage) ([]part, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		if text == "" {
+			return nil, nil
+		}
+		return []part{{Text: text}}, nil
+	}
+
+	var contents []utils.Content
+	if err := json.Unmarshal(raw, &contents); err != nil {
+		return nil, fmt.Errorf("unsupported message content: %v", err)
+	}
+
+	parts := make([]part, 0, len(contents))
+	for _, c := range contents {
+		switch c.Type {
+		case "text":
+			parts = append(parts, part{Text: c.Text})
+		case "image_url":
+			if c.ImageUrl == nil {
+				return nil, fmt.Errorf("image_url content without url")
+			}
+			p, err := convertImage(c.ImageUrl.Url)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, p)
+		default:
+			return nil, fmt.Errorf("unsupported content type %q", c.Type)
+		}
+	}
+	return parts, nil
+}
+
+// convertImage は画像URLをパートに変換します
+// data URL は inlineData に、それ以外のURLは fileData に変換されます
+func convertImage(url string) (part, error) {
+	if !strings.HasPrefix(url, "data:") {
+		mimeType := mime.TypeByExtension(path.Ext(url))
+		if mimeType == "" {
+			mimeType = "image/jpeg"
+		}
+		return part{FileData: &fileData{MimeType: mimeType, FileURI: url}}, nil
+	}
+
+	meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	mimeType, isBase64 := strings.CutSuffix(meta, ";base64")
+	if !ok || !isBase64 {
+		return part{}, fmt.Errorf("unsupported data URL: only base64 encoded images are supported")
+	}
+	return part{InlineData: &blob{MimeType: mimeType, Data: data}}, nil
+}
+
+// convertTools は RequestOptions.Schema に指定されたツール定義を functionDeclarations に変換します
+func convertTools(toolsJSON json.RawMessage) ([]tool, error) {
+	if len(toolsJSON) == 0 {
+		return nil, nil
+	}
+
+	var tools []schema.Tool
+	if err := json.Unmarshal(toolsJSON, &tools); err != nil {
+		return nil, fmt.Errorf("error parsing tools: %v", err)
+	}
+	if len(tools) == 0 {
+		return nil, nil
+	}
+
+	declarations := make([]functionDeclaration, 0, len(tools))
+	for _, t := range tools {
+		declaration := functionDeclaration{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+		}
+		// 引数のない関数は parameters を省略する
+		if len(t.Function.Parameters.Properties) > 0 {
+			parameters, err := ConvertSchema(t.Function.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("error converting parameters of tool %s: %v", t.Function.Name, err)
+			}
+			declaration.Parameters = parameters
+		}
+		declarations = append(declarations, declaration)
+	}
+	return []tool{{FunctionDeclarations: declarations}}, nil
+}
+
+// convertToolChoice は tool_choice を functionCallingConfig に変換します
+// Geminiには並列呼び出しを制御する設定がないため parallel_tool_calls は無視されます
+func convertToolChoice(choice *utils.ToolChoice, tools []tool) (*toolConfig, error) {
+	var names []string
+	for _, t := range tools {
+		for _, declaration := range t.FunctionDeclarations {
+			names = append(names, declaration.Name)
+		}
+	}
+	if err := choice.ValidateTools(names); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	switch {
+	case choice.Function != "":
+		return &toolConfig{FunctionCallingConfig: functionCallingConfig{
+			Mode:                 "ANY",
+			AllowedFunctionNames: []string{choice.Function},
+		}}, nil
+	case choice.Mode == "required":
+		return &toolConfig{FunctionCallingConfig: functionCallingConfig{Mode: "ANY"}}, nil
+	case choice.Mode == "none":
+		return &toolConfig{FunctionCallingConfig: functionCallingConfig{Mode: "NONE"}}, nil
+	default:
+		return &toolConfig{FunctionCallingConfig: functionCallingConfig{Mode: "AUTO"}}, nil
+	}
+}
+
+// finishReason はGeminiのfinishReasonをOpenAI形式のfinish_reasonに変換します
+func finishReason(reason string) string {
+	switch reason {
+	case "STOP":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content_filter"
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+// convertUsage はGeminiのトークン使用量を utils.Usage に変換します
+// 思考に使われたトークンは出力トークンに含めます
+func convertUsage(u usageMetadata) utils.Usage {
+	completion := u.CandidatesTokenCount + u.ThoughtsTokenCount
+	return utils.Usage{
+		PromptTokens:            u.PromptTokenCount,
+		PromptTokensDetails:     utils.PromptTokenDetails{CachedTokens: u.CachedContentTokenCount},
+		CompletionTokens:        completion,
+		CompletionTokensDetails: utils.CompletionTokenDetails{ReasoningTokens: u.ThoughtsTokenCount},
+		TotalTokens:             u.PromptTokenCount + completion,
+	}
+}
+
+// toChatCompletion はレスポンスを utils.ChatCompletion に変換します
+// 候補が返らなかった場合（プロンプトがブロックされた場合）は content_filter で終了した選択肢を返します
+func toChatCompletion(resp *generateContentResponse) *utils.ChatCompletion {
+	completion := &utils.ChatCompletion{
+		ID:     resp.ResponseID,
+		Model:  resp.ModelVersion,
+		Object: "chat.completion",
+		Usage:  convertUsage(resp.UsageMetadata),
+	}
+
+	if len(resp.Candidates) == 0 {
+		reason := "stop"
+		if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+			reason = "content_filter"
+		}
+		completion.Choices = []utils.Choice{{FinishReason: reason, Message: utils.ChatMessage{Role: "assistant"}}}
+		return completion
+	}
+
+	for _, cand := range resp.Candidates {
+		msg := utils.ChatMessage{Role: "assistant"}
+		var texts []string
+		for i, p := range cand.Content.Parts {
+			switch {
+			case p.FunctionCall != nil:
+				id := p.FunctionCall.ID
+				if id == "" {
+					id = fmt.Sprintf("call_%d_%d", cand.Index, i)
+				}
+				args := string(p.FunctionCall.Args)
+				if args == "" {
+					args = "{}"
+				}
+				msg.ToolCalls = append(msg.ToolCalls, utils.ToolCall{
+					ID:       id,
+					Type:     "function",
+					Function: utils.Function{Name: p.FunctionCall.Name, Arguments: args},
+				})
+			case p.Text != "":
+				texts = append(texts, p.Text)
+			}
+		}
+		if len(texts) > 0 {
+			msg.Content, _ = json.Marshal(strings.Join(texts, ""))
+		}
+
+		reason := finishReason(cand.FinishReason)
+		// Geminiは関数呼び出しでも STOP を返すため、呼び出しがあれば tool_calls とする
+		if len(msg.ToolCalls) > 0 && reason == "stop" {
+			reason = "tool_calls"
+		}
+		completion.Choices = append(completion.Choices, utils.Choice{
+			Index:        cand.Index,
+			FinishReason: reason,
+			Message:      msg,
+		})
+	}
+	return completion
+}
@@ -0,0 +1,350 @@
+package gemini_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuki5155/go-llms/gemini-llm/gemini"
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// newTestServer はリクエストボディを検査して固定のレスポンスを返すgenerateContentの代替サーバーを作成します
+func newTestServer(t *testing.T, check func(body map[string]any), response string) *gemini.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			t.Errorf("missing api key header: %v", r.Header)
+		}
+		if !strings.HasSuffix(r.URL.Path, "/models/gemini-test:generateContent") {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		check(body)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+
+	config := gemini.NewClientConfig("test-key")
+	config.BaseURL = server.URL
+	config.Model = "gemini-test"
+	return gemini.NewClient(config)
+}
+
+func TestConvertSchema(t *testing.T) {
+	converted, err := gemini.ConvertSchema(schema.NewImageAnalysisSchema().Schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := json.Marshal(converted)
+	if strings.Contains(string(data), "additionalProperties") {
+		t.Errorf("expected additionalProperties to be dropped: %s", data)
+	}
+	if converted.Type != "OBJECT" || converted.Properties["description"].Type != "STRING" {
+		t.Errorf("expected uppercase types, got %s", data)
+	}
+	if len(converted.Required) != 3 || len(converted.PropertyOrdering) != 3 {
+		t.Errorf("expected required and ordering to be kept: %s", data)
+	}
+
+	objects, err := gemini.ConvertSchema(schema.MustFrom[schema.ObjectAnalysisResponse]())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items := objects.Properties["objects"]
+	if items.Type != "ARRAY" || items.Items == nil || items.Items.Type != "OBJECT" || items.Items.Properties["name"].Type != "STRING" {
+		t.Errorf("unexpected objects schema: %+v", items)
+	}
+
+	type Item struct {
+		Unit  string  `json:"unit" jsonschema:"enum=C|F"`
+		Note  *string `json:"note"`
+		Count int     `json:"count"`
+	}
+	itemSchema, err := gemini.ConvertSchema(schema.MustFrom[Item]())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if unit := itemSchema.Properties["unit"]; unit.Format != "enum" || len(unit.Enum) != 2 {
+		t.Errorf("expected enum format, got %+v", unit)
+	}
+	if note := itemSchema.Properties["note"]; note.Type != "STRING" || !note.Nullable {
+		t.Errorf("expected nullable string, got %+v", note)
+	}
+	if count := itemSchema.Properties["count"]; count.Type != "INTEGER" {
+		t.Errorf("expected integer, got %+v", count)
+	}
+
+	if _, err := gemini.ConvertSchema(schema.BaseSchema{
+		Type:       "object",
+		Properties: map[string]schema.SchemaProperty{"list": {Type: "array"}},
+	}); err == nil || !strings.Contains(err.Error(), "#/properties/list") {
+		t.Errorf("expected error with path for array without items, got %v", err)
+	}
+
+	withDefs, err := gemini.ConvertSchema(schema.BaseSchema{
+		Type: "object",
+		Properties: map[string]schema.SchemaProperty{
+			"kind":  {Type: "string", Const: "point"},
+			"at":    {Type: "string", Format: "date-time"},
+			"email": {Type: "string", Format: "email"},
+			"x":     {Ref: "#/$defs/coord", Nullable: true},
+		},
+		Required: []string{"kind", "at", "email", "x"},
+		Defs: map[string]schema.SchemaProperty{
+			"coord": {Type: "number", Minimum: utils.Ptr(0.0), ExclusiveMaximum: utils.Ptr(1.0)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if kind := withDefs.Properties["kind"]; kind.Format != "enum" || len(kind.Enum) != 1 || kind.Enum[0] != "point" {
+		t.Errorf("expected const as single enum, got %+v", kind)
+	}
+	if at, email := withDefs.Properties["at"], withDefs.Properties["email"]; at.Format != "date-time" || email.Format != "" {
+		t.Errorf("expected only supported formats, got %+v %+v", at, email)
+	}
+	if x := withDefs.Properties["x"]; x.Type != "NUMBER" || !x.Nullable || x.Minimum == nil || *x.Minimum != 0 {
+		t.Errorf("expected inlined nullable number, got %+v", x)
+	}
+
+	for ref, want := range map[string]string{
+		"#/definitions/coord": "unsupported $ref",
+		"#/$defs/missing":     "undefined $ref",
+	} {
+		_, err := gemini.ConvertSchema(schema.BaseSchema{
+			Type:       "object",
+			Properties: map[string]schema.SchemaProperty{"x": {Ref: ref}},
+			Required:   []string{"x"},
+		})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q error for %s, got %v", want, ref, err)
+		}
+	}
+
+	if _, err := gemini.ConvertSchema(schema.MustFrom[struct {
+		Name     string `json:"name"`
+		Children []node `json:"children"`
+	}]()); err == nil || !strings.Contains(err.Error(), "recursive") {
+		t.Errorf("expected error for recursive schema, got %v", err)
+	}
+}
+
+type node struct {
+	Name     string `json:"name"`
+	Children []node `json:"children"`
+}
+
+func TestSendRequestWithFunctionCall(t *testing.T) {
+	client := newTestServer(t, func(body map[string]any) {
+		system := body["systemInstruction"].(map[string]any)["parts"].([]any)[0].(map[string]any)
+		if system["text"] != "You are a helpful assistant." {
+			t.Errorf("expected system instruction, got %v", system)
+		}
+		contents := body["contents"].([]any)
+		if len(contents) != 3 {
+			t.Errorf("expected 3 contents, got %d: %v", len(contents), contents)
+			return
+		}
+
+		first := contents[0].(map[string]any)["parts"].([]any)
+		inline := first[0].(map[string]any)["inlineData"].(map[string]any)
+		if inline["mimeType"] != "image/jpeg" || inline["data"] != "AQID" {
+			t.Errorf("unexpected inline data: %v", inline)
+		}
+
+		model := contents[1].(map[string]any)
+		call := model["parts"].([]any)[0].(map[string]any)["functionCall"].(map[string]any)
+		if model["role"] != "model" || call["name"] != "weather" || call["args"].(map[string]any)["location"] != "Tokyo" {
+			t.Errorf("unexpected model content: %v", model)
+		}
+
+		last := contents[2].(map[string]any)
+		parts := last["parts"].([]any)
+		if last["role"] != "user" || len(parts) != 2 {
+			t.Errorf("expected merged user content with function response and text, got %v", last)
+			return
+		}
+		response := parts[0].(map[string]any)["functionResponse"].(map[string]any)
+		if response["name"] != "weather" || response["response"].(map[string]any)["temperature"] != float64(20) {
+			t.Errorf("unexpected function response: %v", response)
+		}
+
+		config := body["toolConfig"].(map[string]any)["functionCallingConfig"].(map[string]any)
+		if config["mode"] != "ANY" || config["allowedFunctionNames"].([]any)[0] != "weather" {
+			t.Errorf("unexpected function calling config: %v", config)
+		}
+
+		declarations := body["tools"].([]any)[0].(map[string]any)["functionDeclarations"].([]any)
+		declaration := declarations[0].(map[string]any)
+		if declaration["name"] != "weather" || declaration["parameters"].(map[string]any)["type"] != "OBJECT" {
+			t.Errorf("unexpected function declaration: %v", declaration)
+		}
+	}, `{
+		"candidates": [{
+			"content": {"role": "model", "parts": [
+				{"text": "Let me check."},
+				{"functionCall": {"name": "weather", "args": {"location": "Osaka"}}}
+			]},
+			"finishReason": "STOP",
+			"index": 0
+		}],
+		"usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 10, "thoughtsTokenCount": 4, "totalTokenCount": 34},
+		"modelVersion": "gemini-test",
+		"responseId": "resp_1"
+	}`)
+
+	toolsJSON, _ := json.Marshal([]schema.Tool{*schema.NewWeatherFunctionCallSchema()})
+	assistant := utils.Message{
+		Role: utils.RoleAssistant,
+		ToolCalls: []utils.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: utils.Function{Name: "weather", Arguments: `{"location":"Tokyo"}`},
+		}},
+	}
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{
+			utils.NewMessage(utils.RoleSystem, "You are a helpful assistant."),
+			utils.NewMessageWithImageBase64([]byte{1, 2, 3}, "What is this?"),
+			assistant,
+			utils.NewToolMessage("call_1", `{"temperature":20}`),
+			utils.NewMessage(utils.RoleUser, "And Osaka?"),
+		},
+		Schema:     toolsJSON,
+		Parameters: &utils.Parameters{ToolChoice: utils.ToolChoiceFunction("weather")},
+	}
+
+	res, err := client.SendRequestWithFunctionCall(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	call, err := res.GetFunctionCall("weather")
+	if err != nil {
+		t.Fatalf("expected tool call: %v", err)
+	}
+	if call.ID == "" || call.Function.Arguments != `{"location": "Osaka"}` {
+		t.Errorf("unexpected tool call: %+v", call)
+	}
+	if res.Choices[0].FinishReason != "tool_calls" || res.Choices[0].Message.Text() != "Let me check." {
+		t.Errorf("unexpected choice: %+v", res.Choices[0])
+	}
+	if res.Usage.CompletionTokens != 14 || res.Usage.CompletionTokensDetails.ReasoningTokens != 4 || res.Usage.TotalTokens != 34 {
+		t.Errorf("unexpected usage: %+v", res.Usage)
+	}
+}
+
+func TestSendRequestWithFunctionCallUnknownToolCall(t *testing.T) {
+	client := newTestServer(t, func(map[string]any) {
+		t.Error("request should not be sent")
+	}, `{}`)
+
+	_, err := client.SendRequestWithFunctionCall(context.Background(), utils.RequestOptions{
+		Messages: []utils.Message{utils.NewToolMessage("missing", "result")},
+	})
+	if err == nil || !strings.Contains(err.Error(), "unknown tool call id") {
+		t.Errorf("expected unknown tool call error, got %v", err)
+	}
+}
+
+func TestSendRequestWithStructuredOutput(t *testing.T) {
+	client := newTestServer(t, func(body map[string]any) {
+		config := body["generationConfig"].(map[string]any)
+		if config["responseMimeType"] != "application/json" {
+			t.Errorf("expected json mime type, got %v", config)
+		}
+		if config["temperature"] != float64(0) || config["maxOutputTokens"] != float64(512) {
+			t.Errorf("expected parameters in generationConfig, got %v", config)
+		}
+		responseSchema := config["responseSchema"].(map[string]any)
+		if responseSchema["type"] != "OBJECT" || responseSchema["additionalProperties"] != nil {
+			t.Errorf("unexpected response schema: %v", responseSchema)
+		}
+	}, `{
+		"candidates": [{
+			"content": {"role": "model", "parts": [
+				{"text": "{\"location\": \"Tokyo\", \"temperature\": 22, \"unit\": \"C\", \"conditions\": \"Clear\"}"}
+			]},
+			"finishReason": "STOP"
+		}],
+		"usageMetadata": {"promptTokenCount": 30, "candidatesTokenCount": 12, "totalTokenCount": 42}
+	}`)
+
+	schemaJSON, _ := json.Marshal(schema.NewWeatherSchema())
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "What's the weather like in Tokyo today?")},
+		Schema:   schemaJSON,
+		Parameters: &utils.Parameters{
+			Temperature:         utils.Ptr(0.0),
+			MaxCompletionTokens: utils.Ptr(512),
+		},
+	}
+	res, err := client.SendRequestWithStructuredOutput(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	weather, err := utils.HandleResponse[schema.WeatherResponse](res)
+	if err != nil {
+		t.Fatalf("unexpected error handling response: %v", err)
+	}
+	if weather.Location != "Tokyo" || weather.Temperature != 22 || weather.Unit != "C" {
+		t.Errorf("unexpected weather: %+v", weather)
+	}
+}
+
+func TestSendRequestWithStructuredOutputSafety(t *testing.T) {
+	client := newTestServer(t, func(map[string]any) {}, `{
+		"candidates": [{"content": {"role": "model", "parts": []}, "finishReason": "SAFETY"}]
+	}`)
+
+	schemaJSON, _ := json.Marshal(schema.NewWeatherSchema())
+	res, err := client.SendRequestWithStructuredOutput(context.Background(), utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "...")},
+		Schema:   schemaJSON,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := utils.HandleResponse[schema.WeatherResponse](res); !errors.Is(err, utils.ErrContentFilter) {
+		t.Errorf("expected content_filter error, got %v", err)
+	}
+}
+
+func TestSendRequestContextErrors(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	config := gemini.NewClientConfig("test-key")
+	config.BaseURL = server.URL
+	client := gemini.NewClient(config)
+	opts := utils.RequestOptions{Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.SendRequestWithFunctionCall(ctx, opts); !errors.Is(err, utils.ErrRequestTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected ErrRequestTimeout, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := client.SendRequestWithFunctionCall(ctx, opts); !errors.Is(err, utils.ErrRequestCanceled) {
+		t.Errorf("expected ErrRequestCanceled, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package gemini
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+)
+
+// Schema はGeminiの responseSchema や関数パラメータで使用するOpenAPIのサブセットです
+type Schema struct {
+	Type             string            `json:"type,omitempty"`
+	Title            string            `json:"title,omitempty"`
+	Format           string            `json:"format,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	Nullable         bool              `json:"nullable,omitempty"`
+	Enum             []string          `json:"enum,omitempty"`
+	Items            *Schema           `json:"items,omitempty"`
+	MinItems         *int              `json:"minItems,omitempty"`
+	MaxItems         *int              `json:"maxItems,omitempty"`
+	Minimum          *float64          `json:"minimum,omitempty"`
+	Maximum          *float64          `json:"maximum,omitempty"`
+	Pattern          string            `json:"pattern,omitempty"`
+	AnyOf            []Schema          `json:"anyOf,omitempty"`
+	Properties       map[string]Schema `json:"properties,omitempty"`
+	Required         []string          `json:"required,omitempty"`
+	PropertyOrdering []string          `json:"propertyOrdering,omitempty"`
+}
+
+// supportedFormats はGeminiが受け付ける文字列の format です（それ以外は変換時に除かれます）
+var supportedFormats = []string{"date-time", "date", "time"}
+
+// ConvertSchema はBaseSchemaをGeminiのSchemaに変換します
+// additionalProperties、exclusiveMinimum などGeminiでは指定できないキーワードは変換されません
+// $ref は $defs の定義を展開して変換しますが、再帰的なスキーマはエラーになります
+func ConvertSchema(s schema.BaseSchema) (*Schema, error) {
+	c := &converter{defs: s.Defs}
+	return c.convert(schema.SchemaProperty{
+		Type:       s.Type,
+		Properties: s.Properties,
+		Required:   s.Required,
+	}, "#")
+}
+
+// converter は展開中の $ref を追跡しながらSchemaPropertyを変換します
+type converter struct {
+	defs     map[string]schema.SchemaProperty
+	visiting []string
+}
+
+// convert はSchemaPropertyを変換します
+func (c *converter) convert(p schema.SchemaProperty, path string) (*Schema, error) {
+	if p.Ref != "" {
+		return c.convertRef(p, path)
+	}
+
+	converted := &Schema{
+		Title:       p.Title,
+		Description: p.Description,
+		Nullable:    p.Nullable,
+		Enum:        p.Enum,
+	}
+
+	if len(p.AnyOf) > 0 {
+		for i, branch := range p.AnyOf {
+			if branch.Type == "null" {
+				converted.Nullable = true
+				continue
+			}
+			child, err := c.convert(branch, fmt.Sprintf("%s/anyOf/%d", path, i))
+			if err != nil {
+				return nil, err
+			}
+			converted.AnyOf = append(converted.AnyOf, *child)
+		}
+		if len(converted.AnyOf) == 1 {
+			// null との組み合わせだけであれば nullable な単一のスキーマにする
+			single := converted.AnyOf[0]
+			single.Nullable = single.Nullable || converted.Nullable
+			if converted.Description != "" {
+				single.Description = converted.Description
+			}
+			return &single, nil
+		}
+		return converted, nil
+	}
+
+	switch p.Type {
+	case "object":
+		converted.Type = "OBJECT"
+		converted.Properties = make(map[string]Schema, len(p.Properties))
+		for name, prop := range p.Properties {
+			child, err := c.convert(prop, path+"/properties/"+name)
+			if err != nil {
+				return nil, err
+			}
+			converted.Properties[name] = *child
+		}
+		converted.Required = p.Required
+		converted.PropertyOrdering = propertyOrdering(p)
+	case "array":
+		converted.Type = "ARRAY"
+		if p.Items == nil {
+			return nil, fmt.Errorf("%s: array schema requires items", path)
+		}
+		items, err := c.convert(*p.Items, path+"/items")
+		if err != nil {
+			return nil, err
+		}
+		converted.Items = items
+		converted.MinItems = p.MinItems
+		converted.MaxItems = p.MaxItems
+	case "string":
+		converted.Type = "STRING"
+		converted.Pattern = p.Pattern
+		if slices.Contains(supportedFormats, p.Format) {
+			converted.Format = p.Format
+		}
+		if p.Const != nil {
+			value, ok := p.Const.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: unsupported const value %v", path, p.Const)
+			}
+			converted.Enum = []string{value}
+		}
+		if len(converted.Enum) > 0 {
+			converted.Format = "enum"
+		}
+	case "number", "integer":
+		converted.Type = strings.ToUpper(p.Type)
+		converted.Minimum = p.Minimum
+		converted.Maximum = p.Maximum
+	case "boolean":
+		converted.Type = "BOOLEAN"
+	default:
+		return nil, fmt.Errorf("%s: unsupported schema type %q", path, p.Type)
+	}
+	if p.Const != nil && p.Type != "string" {
+		return nil, fmt.Errorf("%s: unsupported const value %v", path, p.Const)
+	}
+	return converted, nil
+}
+
+// convertRef は $defs の定義を展開して変換します
+func (c *converter) convertRef(p schema.SchemaProperty, path string) (*Schema, error) {
+	if p.Ref == "#" {
+		return nil, fmt.Errorf("%s: recursive schema reference %q is not supported", path, p.Ref)
+	}
+	name, ok := strings.CutPrefix(p.Ref, "#/$defs/")
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported $ref %q", path, p.Ref)
+	}
+	def, ok := c.defs[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: undefined $ref %q", path, p.Ref)
+	}
+	if slices.Contains(c.visiting, name) {
+		return nil, fmt.Errorf("%s: recursive schema reference %q is not supported", path, p.Ref)
+	}
+
+	c.visiting = append(c.visiting, name)
+	defer func() { c.visiting = c.visiting[:len(c.visiting)-1] }()
+
+	converted, err := c.convert(def, path)
+	if err != nil {
+		return nil, err
+	}
+	if p.Description != "" {
+		converted.Description = p.Description
+	}
+	converted.Nullable = converted.Nullable || p.Nullable
+	return converted, nil
+}
+
+// propertyOrdering は required の順序を基にプロパティの出力順を決めます
+func propertyOrdering(p schema.SchemaProperty) []string {
+	if len(p.Properties) == 0 {
+		return nil
+	}
+	ordering := make([]string, 0, len(p.Properties))
+	seen := make(map[string]bool, len(p.Properties))
+	for _, name := range p.Required {
+		if _, ok := p.Properties[name]; ok && !seen[name] {
+			ordering = append(ordering, name)
+			seen[name] = true
+		}
+	}
+	if len(ordering) != len(p.Properties) {
+		// required に含まれないプロパティがある場合は順序を指定しない
+		return nil
+	}
+	return ordering
+}
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/yuki5155/go-llms/anthropic-llm/anthropic"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+func init() {
+	Register("anthropic", func(cfg Config) (Provider, error) {
+		if err := rejectOpenAIOnly("anthropic", cfg); err != nil {
+			return nil, err
+		}
+		config := anthropic.NewClientConfig(cfg.APIKey)
+		if cfg.Model != "" {
+			config.Model = cfg.Model
+		}
+		if cfg.Endpoint != "" {
+			config.Endpoint = cfg.Endpoint
+		}
+		if cfg.HTTPClient != nil {
+			config.Client = cfg.HTTPClient
+		}
+		config.CostSettings = cfg.CostSettings
+		return NewAnthropic(anthropic.NewClient(config)), nil
+	})
+}
+
+// anthropicProvider は anthropic.Client をProviderとして利用するためのアダプターです
+type anthropicProvider struct {
+	client *anthropic.Client
+}
+
+// NewAnthropic は anthropic.Client をラップしたProviderを作成します
+// ストリーミングはレスポンス全体を1つのチャンクとして返します
+func NewAnthropic(client *anthropic.Client) Provider {
+	return &anthropicProvider{client: client}
+}
+
+func (p *anthropicProvider) Name() string {
+	return "anthropic"
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, req *Request) (*Response, error) {
+	opts, err := toolOptions(req)
+	if err != nil {
+		return nil, err
+	}
+	return p.client.SendRequestWithFunctionCall(ctx, opts)
+}
+
+func (p *anthropicProvider) Structured(ctx context.Context, req *Request) (*utils.APIResponse, error) {
+	opts, err := structuredOptions(req)
+	if err != nil {
+		return nil, err
+	}
+	return p.client.SendRequestWithStructuredOutput(ctx, opts)
+}
+
+func (p *anthropicProvider) Stream(ctx context.Context, req *Request) (Stream, error) {
+	resp, err := p.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return newCompletionStream(resp), nil
+}
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/yuki5155/go-llms/gemini-llm/gemini"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+func init() {
+	Register("gemini", func(cfg Config) (Provider, error) {
+		if err := rejectOpenAIOnly("gemini", cfg); err != nil {
+			return nil, err
+		}
+		config := gemini.NewClientConfig(cfg.APIKey)
+		if cfg.Model != "" {
+			config.Model = cfg.Model
+		}
+		if cfg.BaseURL != "" {
+			config.BaseURL = cfg.BaseURL
+		} else if cfg.Endpoint != "" {
+			config.BaseURL = cfg.Endpoint
+		}
+		if cfg.HTTPClient != nil {
+			config.Client = cfg.HTTPClient
+		}
+		config.CostSettings = cfg.CostSettings
+		return NewGemini(gemini.NewClient(config)), nil
+	})
+}
+
+// geminiProvider は gemini.Client をProviderとして利用するためのアダプターです
+type geminiProvider struct {
+	client *gemini.Client
+}
+
+// NewGemini は gemini.Client をラップしたProviderを作成します
+// ストリーミングはレスポンス全体を1つのチャンクとして返します
+func NewGemini(client *gemini.Client) Provider {
+	return &geminiProvider{client: client}
+}
+
+func (p *geminiProvider) Name() string {
+	return "gemini"
+}
+
+func (p *geminiProvider) Chat(ctx context.Context, req *Request) (*Response, error) {
+	opts, err := toolOptions(req)
+	if err != nil {
+		return nil, err
+	}
+	return p.client.SendRequestWithFunctionCall(ctx, opts)
+}
+
+func (p *geminiProvider) Structured(ctx context.Context, req *Request) (*utils.APIResponse, error) {
+	opts, err := structuredOptions(req)
+	if err != nil {
+		return nil, err
+	}
+	return p.client.SendRequestWithStructuredOutput(ctx, opts)
+}
+
+func (p *geminiProvider) Stream(ctx context.Context, req *Request) (Stream, error) {
+	resp, err := p.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return newCompletionStream(resp), nil
+}
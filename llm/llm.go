@@ -0,0 +1,268 @@
+// Package llm はLLMのバックエンドを切り替えて利用するための共通インターフェースを提供します
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yuki5155/go-llms/openai-llm/models"
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// メッセージやレスポンスは全てのバックエンドで共通の型を利用します
+type (
+	Role     = utils.Role
+	Message  = utils.Message
+	ToolCall = utils.ToolCall
+	Usage    = utils.Usage
+	Response = utils.ChatCompletion
+	// 生成パラメーター
+	Parameters = utils.Parameters
+	ToolChoice = utils.ToolChoice
+	// 複数ターンの会話
+	Conversation = utils.Conversation
+	Chunk        = utils.ChatCompletionChunk
+	// チャンクの構成要素
+	ChunkChoice   = utils.ChunkChoice
+	ChunkDelta    = utils.ChunkDelta
+	ToolCallDelta = utils.ToolCallDelta
+)
+
+const (
+	RoleSystem    = utils.RoleSystem
+	RoleUser      = utils.RoleUser
+	RoleAssistant = utils.RoleAssistant
+	RoleTool      = utils.RoleTool
+)
+
+var (
+	NewMessage                = utils.NewMessage
+	NewMessageWithImage       = utils.NewMessageWithImage
+	NewMessageWithImageBase64 = utils.NewMessageWithImageBase64
+	NewToolMessage            = utils.NewToolMessage
+	NewConversation           = utils.NewConversation
+)
+
+// Request はバックエンドに依存しないリクエストを定義します
+type Request struct {
+	Messages []Message
+	// Tools はモデルが呼び出せるツールです
+	Tools []schema.Tool
+	// ResponseSchema は構造化出力で使用するスキーマです（Structured でのみ使用）
+	ResponseSchema *schema.ResponseSchema
+	// Parameters は生成パラメーターです（バックエンドが対応しない項目は無視されます）
+	Parameters *Parameters
+}
+
+// Provider はLLMのバックエンドが実装するインターフェースです
+type Provider interface {
+	// Name はバックエンドの名前を返します
+	Name() string
+	// Chat はメッセージとツールを送信し、レスポンスを返します
+	Chat(ctx context.Context, req *Request) (*Response, error)
+	// Structured は ResponseSchema に従った構造化出力を要求します
+	// 戻り値は utils.HandleResponse でパースできます
+	Structured(ctx context.Context, req *Request) (*utils.APIResponse, error)
+	// Stream は Chat と同じリクエストをストリーミングで送信します
+	Stream(ctx context.Context, req *Request) (Stream, error)
+}
+
+// Stream はストリーミングレスポンスのチャンクを順に読み出します
+type Stream interface {
+	// Recv は次のチャンクを返します（終了時は io.EOF）
+	Recv() (*Chunk, error)
+	// Completion は受信済みのチャンクから組み立てたレスポンスを返します
+	Completion() *Response
+	Close() error
+}
+
+// Config はバックエンドの選択と接続情報を定義します
+type Config struct {
+	// Provider はバックエンドの名前です（空の場合は "openai"）
+	Provider string
+	APIKey   string
+	Model    string
+	// Endpoint はAPIのURLを上書きします（空の場合は各バックエンドのデフォルト）
+	Endpoint string
+	// BaseURL はOpenAI互換サーバーのベースURLです（例: http://localhost:11434/v1）
+	BaseURL string
+	// APIVersion はAzure OpenAIの api-version です
+	APIVersion string
+	HTTPClient *http.Client
+	// Retry はOpenAI系のバックエンド（openai・azure・ollama・openai-compatible）の再試行方針です
+	// anthropic・gemini では使用できず、指定した場合は New がエラーを返します
+	Retry *utils.RetryPolicy
+	// Models はOpenAI系のバックエンドがモデルの機能を確認するためのレジストリです（nilの場合は models.Default()）
+	// anthropic・gemini では使用できず、指定した場合は New がエラーを返します
+	Models *models.Registry
+	// CostSettings は全てのバックエンドに渡すコストの集計の設定です
+	utils.CostSettings
+}
+
+// DefaultProvider は Config.Provider が空の場合に使用されるバックエンドです
+const DefaultProvider = "openai"
+
+// Factory は Config からProviderを作成する関数です
+type Factory func(cfg Config) (Provider, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{}
+)
+
+// Register はバックエンドを名前で登録します
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// Providers は登録済みのバックエンド名を返します
+func Providers() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New は Config.Provider に対応するProviderを作成します
+func New(cfg Config) (Provider, error) {
+	name := cfg.Provider
+	if name == "" {
+		name = DefaultProvider
+	}
+
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (available: %s)", name, strings.Join(Providers(), ", "))
+	}
+	return factory(cfg)
+}
+
+// ConfigFromEnv は環境変数からConfigを作成します
+//
+//	LLM_PROVIDER  バックエンド名（デフォルト: openai）
+//	LLM_API_KEY   APIキー（未設定の場合は APIKeyEnv の環境変数、例: OPENAI_API_KEY）
+//	LLM_MODEL     モデル名
+//	LLM_ENDPOINT  APIのURL
+//	LLM_BASE_URL  OpenAI互換サーバーのベースURL
+//	LLM_API_VERSION  Azure OpenAIの api-version
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Provider:   os.Getenv("LLM_PROVIDER"),
+		APIKey:     os.Getenv("LLM_API_KEY"),
+		Model:      os.Getenv("LLM_MODEL"),
+		Endpoint:   os.Getenv("LLM_ENDPOINT"),
+		BaseURL:    os.Getenv("LLM_BASE_URL"),
+		APIVersion: os.Getenv("LLM_API_VERSION"),
+	}
+	if cfg.Provider == "" {
+		cfg.Provider = DefaultProvider
+	}
+	if cfg.APIKey == "" {
+		cfg.APIKey = os.Getenv(APIKeyEnv(cfg.Provider))
+	}
+	return cfg
+}
+
+// APIKeyEnv はバックエンドのAPIキーを読み込む環境変数の名前を返します
+// 環境変数名に使えない "-" は "_" に置き換えます（例: openai-compatible は OPENAI_COMPATIBLE_API_KEY）
+func APIKeyEnv(provider string) string {
+	return strings.ToUpper(strings.ReplaceAll(provider, "-", "_")) + "_API_KEY"
+}
+
+// RequiresAPIKey はバックエンドの利用にAPIキーが必要かを判定します
+// ollama・openai-compatible のように認証が任意のバックエンドでは false を返します
+func RequiresAPIKey(provider string) bool {
+	if provider == "" {
+		provider = DefaultProvider
+	}
+	return !keylessProviders[provider]
+}
+
+// Complete は型 T からスキーマを生成して構造化出力を要求し、パースした結果を返します
+func Complete[T any](ctx context.Context, p Provider, messages []Message) (*T, error) {
+	responseSchema, err := schema.NewResponseSchema[T]()
+	if err != nil {
+		return nil, fmt.Errorf("error generating schema: %w", err)
+	}
+
+	resp, err := p.Structured(ctx, &Request{
+		Messages:       messages,
+		ResponseSchema: responseSchema,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return utils.HandleResponse[T](resp)
+}
+
+// SendConversation は会話の履歴とツールを送信し、アシスタントの応答を履歴に追加します
+// エラーの場合、履歴は変更されません
+func SendConversation(ctx context.Context, p Provider, c *Conversation) (*Response, error) {
+	if c.ID != "" {
+		ctx = utils.WithConversationID(ctx, c.ID)
+	}
+	resp, err := p.Chat(ctx, &Request{
+		Messages:   c.Messages,
+		Tools:      c.Tools,
+		Parameters: c.Parameters,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return resp, fmt.Errorf("no choices available")
+	}
+	c.AddReply(resp.Choices[0].Message)
+	return resp, nil
+}
+
+// rejectOpenAIOnly はOpenAI系のバックエンドでのみ使用できる設定が指定されている場合にエラーを返します
+func rejectOpenAIOnly(name string, cfg Config) error {
+	if cfg.Retry != nil {
+		return fmt.Errorf("retry policy is not supported by the %s provider", name)
+	}
+	if cfg.Models != nil {
+		return fmt.Errorf("model registry is not supported by the %s provider", name)
+	}
+	return nil
+}
+
+// toolOptions はRequestをツール定義を含む utils.RequestOptions に変換します
+func toolOptions(req *Request) (utils.RequestOptions, error) {
+	opts := utils.RequestOptions{Messages: req.Messages, Parameters: req.Parameters}
+	if len(req.Tools) > 0 {
+		toolsJSON, err := json.Marshal(req.Tools)
+		if err != nil {
+			return utils.RequestOptions{}, fmt.Errorf("error marshalling tools: %v", err)
+		}
+		opts.Schema = toolsJSON
+	}
+	return opts, nil
+}
+
+// structuredOptions はRequestをスキーマを含む utils.RequestOptions に変換します
+func structuredOptions(req *Request) (utils.RequestOptions, error) {
+	if req.ResponseSchema == nil {
+		return utils.RequestOptions{}, fmt.Errorf("response schema is required")
+	}
+	schemaJSON, err := json.Marshal(req.ResponseSchema)
+	if err != nil {
+		return utils.RequestOptions{}, fmt.Errorf("error marshalling schema: %v", err)
+	}
+	return utils.RequestOptions{Messages: req.Messages, Schema: schemaJSON, Parameters: req.Parameters}, nil
+}
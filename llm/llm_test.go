@@ -0,0 +1,157 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yuki5155/go-llms/llm"
+	"github.com/yuki5155/go-llms/openai-llm/models"
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+func newOpenAIServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+
+		switch {
+		case body["stream"] != nil:
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hi\"},\"finish_reason\":\"stop\"}]}\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+		case body["response_format"] != nil:
+			io.WriteString(w, `{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"{\"location\":\"Tokyo\",\"temperature\":18,\"unit\":\"C\",\"conditions\":\"Cloudy\"}"}}]}`)
+		default:
+			if body["tools"] == nil {
+				t.Errorf("expected tools in chat request")
+			}
+			io.WriteString(w, `{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"location\":\"Tokyo\"}"}}]}}]}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAIProvider(t *testing.T) {
+	server := newOpenAIServer(t)
+	provider, err := llm.New(llm.Config{Provider: "openai", APIKey: "test-key", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if provider.Name() != "openai" {
+		t.Errorf("unexpected provider name: %s", provider.Name())
+	}
+
+	ctx := context.Background()
+	messages := []llm.Message{llm.NewMessage(llm.RoleUser, "What's the weather like in Tokyo today?")}
+
+	resp, err := provider.Chat(ctx, &llm.Request{
+		Messages: messages,
+		Tools:    []schema.Tool{*schema.NewWeatherFunctionCallSchema()},
+	})
+	if err != nil {
+		t.Fatalf("unexpected chat error: %v", err)
+	}
+	if _, err := resp.GetFunctionCall("weather"); err != nil {
+		t.Errorf("expected weather tool call: %v", err)
+	}
+
+	weather, err := llm.Complete[schema.WeatherResponse](ctx, provider, messages)
+	if err != nil {
+		t.Fatalf("unexpected structured error: %v", err)
+	}
+	if weather.Location != "Tokyo" || weather.Conditions != "Cloudy" {
+		t.Errorf("unexpected weather: %+v", weather)
+	}
+
+	stream, err := provider.Stream(ctx, &llm.Request{Messages: messages})
+	if err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	defer stream.Close()
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if err != io.EOF {
+				t.Fatalf("unexpected recv error: %v", err)
+			}
+			break
+		}
+	}
+	if got := stream.Completion().Choices[0].Message.Text(); got != "Hi" {
+		t.Errorf("unexpected streamed content: %v", got)
+	}
+}
+
+func TestNewUnknownProvider(t *testing.T) {
+	if _, err := llm.New(llm.Config{Provider: "nope"}); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestProviderName(t *testing.T) {
+	tests := []llm.Config{
+		{Provider: "openai", APIKey: "test-key"},
+		{Provider: "azure", APIKey: "test-key", Endpoint: "https://example.openai.azure.com", Model: "gpt-4o"},
+		{Provider: "ollama", Model: "llama3.2"},
+		{Provider: "openai-compatible", BaseURL: "http://localhost:8080/v1", Model: "llama3.2"},
+	}
+	for _, cfg := range tests {
+		provider, err := llm.New(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", cfg.Provider, err)
+		}
+		if provider.Name() != cfg.Provider {
+			t.Errorf("expected name %q, got %q", cfg.Provider, provider.Name())
+		}
+	}
+}
+
+func TestOpenAIOnlySettingsRejected(t *testing.T) {
+	for _, name := range []string{"anthropic", "gemini"} {
+		if _, err := llm.New(llm.Config{Provider: name, APIKey: "test-key", Retry: utils.NewRetryPolicy()}); err == nil {
+			t.Errorf("expected %s to reject a retry policy", name)
+		}
+		if _, err := llm.New(llm.Config{Provider: name, APIKey: "test-key", Models: models.Default()}); err == nil {
+			t.Errorf("expected %s to reject a model registry", name)
+		}
+		if _, err := llm.New(llm.Config{Provider: name, APIKey: "test-key"}); err != nil {
+			t.Errorf("unexpected error for %s: %v", name, err)
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "openai")
+	t.Setenv("LLM_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	t.Setenv("LLM_MODEL", "gpt-4o-mini")
+
+	cfg := llm.ConfigFromEnv()
+	if cfg.APIKey != "sk-test" || cfg.Model != "gpt-4o-mini" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestConfigFromEnvProviderKey(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "openai-compatible")
+	t.Setenv("LLM_API_KEY", "")
+	t.Setenv("OPENAI_COMPATIBLE_API_KEY", "local-key")
+
+	cfg := llm.ConfigFromEnv()
+	if cfg.APIKey != "local-key" {
+		t.Errorf("expected key from OPENAI_COMPATIBLE_API_KEY, got %q", cfg.APIKey)
+	}
+	if llm.RequiresAPIKey("openai-compatible") || llm.RequiresAPIKey("ollama") {
+		t.Error("expected local servers not to require an API key")
+	}
+	if !llm.RequiresAPIKey("") || !llm.RequiresAPIKey("anthropic") {
+		t.Error("expected hosted providers to require an API key")
+	}
+}
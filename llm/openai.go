@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// keylessProviders は認証が任意のOpenAI互換サーバーのバックエンドです
+var keylessProviders = map[string]bool{"ollama": true, "openai-compatible": true}
+
+func init() {
+	Register("openai", func(cfg Config) (Provider, error) {
+		config := utils.NewClientConfig(cfg.APIKey)
+		if cfg.Model != "" {
+			config.Model = cfg.Model
+		}
+		if cfg.Endpoint != "" {
+			config.Endpoint = cfg.Endpoint
+		}
+		config.BaseURL = cfg.BaseURL
+		if cfg.HTTPClient != nil {
+			config.Client = cfg.HTTPClient
+		}
+		config.Retry = cfg.Retry
+		config.Models = cfg.Models
+		config.CostSettings = cfg.CostSettings
+		return newOpenAIProvider("openai", utils.NewClient(config)), nil
+	})
+
+	// Ollama・llama.cpp server・vLLM などのOpenAI互換サーバー
+	local := func(name, defaultBaseURL string) Factory {
+		return func(cfg Config) (Provider, error) {
+			if cfg.Model == "" {
+				return nil, fmt.Errorf("model is required for local OpenAI-compatible servers")
+			}
+			baseURL := cfg.BaseURL
+			if baseURL == "" {
+				baseURL = defaultBaseURL
+			}
+			if baseURL == "" {
+				return nil, fmt.Errorf("base URL is required for OpenAI-compatible servers")
+			}
+			config := utils.NewLocalClientConfig(baseURL, cfg.Model)
+			config.APIKey = cfg.APIKey
+			if cfg.HTTPClient != nil {
+				config.Client = cfg.HTTPClient
+			}
+			config.Retry = cfg.Retry
+			config.Models = cfg.Models
+			config.CostSettings = cfg.CostSettings
+			return newOpenAIProvider(name, utils.NewClient(config)), nil
+		}
+	}
+	Register("ollama", local("ollama", utils.DefaultOllamaBaseURL))
+	Register("openai-compatible", local("openai-compatible", ""))
+
+	// Azure OpenAI（Endpoint はリソースのURL、Model はデプロイメント名）
+	Register("azure", func(cfg Config) (Provider, error) {
+		if cfg.Endpoint == "" || cfg.Model == "" {
+			return nil, fmt.Errorf("endpoint and deployment (model) are required for Azure OpenAI")
+		}
+		config := utils.NewAzureClientConfig(cfg.Endpoint, cfg.Model, cfg.APIKey)
+		if cfg.APIVersion != "" {
+			config.Azure.APIVersion = cfg.APIVersion
+		}
+		if cfg.HTTPClient != nil {
+			config.Client = cfg.HTTPClient
+		}
+		config.Retry = cfg.Retry
+		config.Models = cfg.Models
+		config.CostSettings = cfg.CostSettings
+		return newOpenAIProvider("azure", utils.NewClient(config)), nil
+	})
+}
+
+// openAIProvider は utils.Client をProviderとして利用するためのアダプターです
+type openAIProvider struct {
+	// name は登録されたバックエンドの名前です（azure・ollama など）
+	name   string
+	client *utils.Client
+}
+
+// NewOpenAI は utils.Client をラップしたProviderを作成します
+func NewOpenAI(client *utils.Client) Provider {
+	return newOpenAIProvider(DefaultProvider, client)
+}
+
+func newOpenAIProvider(name string, client *utils.Client) Provider {
+	return &openAIProvider{name: name, client: client}
+}
+
+func (p *openAIProvider) Name() string {
+	return p.name
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, req *Request) (*Response, error) {
+	opts, err := toolOptions(req)
+	if err != nil {
+		return nil, err
+	}
+	return p.client.SendRequestWithFunctionCallContext(ctx, opts)
+}
+
+func (p *openAIProvider) Structured(ctx context.Context, req *Request) (*utils.APIResponse, error) {
+	opts, err := structuredOptions(req)
+	if err != nil {
+		return nil, err
+	}
+	return p.client.SendRequestWithStructuredOutputContext(ctx, opts)
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, req *Request) (Stream, error) {
+	opts, err := toolOptions(req)
+	if err != nil {
+		return nil, err
+	}
+	return p.client.StreamRequestWithFunctionCall(ctx, opts)
+}
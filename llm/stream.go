@@ -0,0 +1,64 @@
+package llm
+
+import "io"
+
+// completionStream はストリーミングに対応していないバックエンドのレスポンスを、
+// 1つのチャンクとして返すStreamです
+type completionStream struct {
+	resp *Response
+	sent bool
+}
+
+func newCompletionStream(resp *Response) Stream {
+	return &completionStream{resp: resp}
+}
+
+func (s *completionStream) Recv() (*Chunk, error) {
+	if s.sent {
+		return nil, io.EOF
+	}
+	s.sent = true
+
+	chunk := &Chunk{
+		ID:                s.resp.ID,
+		Object:            "chat.completion.chunk",
+		Created:           s.resp.Created,
+		Model:             s.resp.Model,
+		SystemFingerprint: s.resp.SystemFingerprint,
+		Usage:             &s.resp.Usage,
+	}
+	for _, choice := range s.resp.Choices {
+		delta := ChunkDelta{Role: choice.Message.Role, Refusal: choice.Message.Refusal}
+		if len(choice.Message.Content) > 0 && string(choice.Message.Content) != "null" {
+			content := choice.Message.Text()
+			delta.Content = &content
+		}
+		for i, call := range choice.Message.ToolCalls {
+			delta.ToolCalls = append(delta.ToolCalls, ToolCallDelta{
+				Index:    i,
+				ID:       call.ID,
+				Type:     call.Type,
+				Function: call.Function,
+			})
+		}
+		finishReason := choice.FinishReason
+		chunk.Choices = append(chunk.Choices, ChunkChoice{
+			Index:        choice.Index,
+			Delta:        delta,
+			FinishReason: &finishReason,
+			LogProbs:     choice.LogProbs,
+		})
+	}
+	return chunk, nil
+}
+
+func (s *completionStream) Completion() *Response {
+	if !s.sent {
+		return &Response{}
+	}
+	return s.resp
+}
+
+func (s *completionStream) Close() error {
+	return nil
+}
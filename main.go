@@ -1,59 +1,41 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 
+	"github.com/yuki5155/go-llms/llm"
 	"github.com/yuki5155/go-llms/openai-llm/schema"
-	"github.com/yuki5155/go-llms/openai-llm/utils"
 )
 
 func main() {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		fmt.Println("Please set the OPENAI_API_KEY environment variable.")
+	// 環境変数からバックエンドの設定を読み込み（LLM_PROVIDER で切り替え）
+	cfg := llm.ConfigFromEnv()
+	if cfg.APIKey == "" && llm.RequiresAPIKey(cfg.Provider) {
+		fmt.Printf("Please set the %s (or LLM_API_KEY) environment variable.\n", llm.APIKeyEnv(cfg.Provider))
 		return
 	}
 
-	// utilsクライアントの設定と作成
-	config := utils.NewClientConfig(apiKey)
-	client := utils.NewClient(config)
-
-	// WeatherSchemaの作成
-	weatherSchema := schema.NewWeatherSchema()
-	schemaJSON, err := json.Marshal(weatherSchema)
+	provider, err := llm.New(cfg)
 	if err != nil {
-		fmt.Printf("Error marshalling weather schema: %v\n", err)
+		fmt.Printf("Error creating provider: %v\n", err)
 		return
 	}
 
 	// メッセージの準備
-	messages := []utils.Message{
-		utils.NewMessage(utils.RoleSystem, "You are a helpful assistant designed to output weather information in JSON format."),
-		utils.NewMessage(utils.RoleUser, "What's the weather like in Tokyo today?"),
-	}
-
-	// リクエストオプションの作成
-	opts := utils.RequestOptions{
-		Messages: messages,
-		Schema:   schemaJSON,
+	messages := []llm.Message{
+		llm.NewMessage(llm.RoleSystem, "You are a helpful assistant designed to output weather information in JSON format."),
+		llm.NewMessage(llm.RoleUser, "What's the weather like in Tokyo today?"),
 	}
 
-	// utils APIにリクエストを送信
-	resp, err := client.SendRequestWithStructuredOutput(opts)
+	// WeatherResponseのスキーマで構造化出力を要求
+	weather, err := llm.Complete[schema.WeatherResponse](context.Background(), provider, messages)
 	if err != nil {
 		fmt.Printf("Error sending request: %v\n", err)
 		return
 	}
 
-	// レスポンスの処理
-	weather, err := utils.HandleResponse[schema.WeatherResponse](resp)
-	if err != nil {
-		fmt.Printf("Error handling response: %v\n", err)
-		return
-	}
-
 	// キーを指定して情報を取得
 	fmt.Printf("Temperature: %v\n", weather.Temperature)
 
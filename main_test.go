@@ -83,6 +83,11 @@ func TestFunctionCall(t *testing.T) {
 		fmt.Printf("Error handling response: %v\n", err)
 		return
 	}
-	fmt.Println(item[0].Function.Arguments)
+	args, err := utils.DecodeArguments[schema.WeatherFunctionArgs](item[0], utils.WithTool(*weatherSchema))
+	if err != nil {
+		fmt.Printf("Error decoding arguments: %v\n", err)
+		return
+	}
+	fmt.Println(args.Location)
 
 }
@@ -0,0 +1,80 @@
+package models
+
+// builtin は組み込みのモデルです（料金は100万トークンあたりのUSD）
+var builtin = []Model{
+	// OpenAI
+	{ID: "gpt-5", Provider: ProviderOpenAI, ContextWindow: 400000, MaxOutputTokens: 128000, Encoding: "o200k_base",
+		Vision: true, Tools: true, StructuredOutputs: true, ReasoningEffort: true,
+		Pricing: Pricing{Input: 1.25, CachedInput: 0.125, Output: 10}},
+	{ID: "gpt-5-mini", Provider: ProviderOpenAI, ContextWindow: 400000, MaxOutputTokens: 128000, Encoding: "o200k_base",
+		Vision: true, Tools: true, StructuredOutputs: true, ReasoningEffort: true,
+		Pricing: Pricing{Input: 0.25, CachedInput: 0.025, Output: 2}},
+	{ID: "gpt-5-nano", Provider: ProviderOpenAI, ContextWindow: 400000, MaxOutputTokens: 128000, Encoding: "o200k_base",
+		Vision: true, Tools: true, StructuredOutputs: true, ReasoningEffort: true,
+		Pricing: Pricing{Input: 0.05, CachedInput: 0.005, Output: 0.4}},
+	{ID: "gpt-4.1", Provider: ProviderOpenAI, ContextWindow: 1047576, MaxOutputTokens: 32768, Encoding: "o200k_base",
+		Vision: true, Tools: true, StructuredOutputs: true,
+		Pricing: Pricing{Input: 2, CachedInput: 0.5, Output: 8}},
+	{ID: "gpt-4.1-mini", Provider: ProviderOpenAI, ContextWindow: 1047576, MaxOutputTokens: 32768, Encoding: "o200k_base",
+		Vision: true, Tools: true, StructuredOutputs: true,
+		Pricing: Pricing{Input: 0.4, CachedInput: 0.1, Output: 1.6}},
+	{ID: "gpt-4.1-nano", Provider: ProviderOpenAI, ContextWindow: 1047576, MaxOutputTokens: 32768, Encoding: "o200k_base",
+		Vision: true, Tools: true, StructuredOutputs: true,
+		Pricing: Pricing{Input: 0.1, CachedInput: 0.025, Output: 0.4}},
+	{ID: "gpt-4o", Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutputTokens: 16384, Encoding: "o200k_base",
+		Vision: true, Tools: true, StructuredOutputs: true,
+		Pricing: Pricing{Input: 2.5, CachedInput: 1.25, Output: 10}},
+	// json_schema は 2024-08-06 以降のスナップショットのみ対応
+	{ID: "gpt-4o-2024-05-13", Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutputTokens: 4096, Encoding: "o200k_base",
+		Vision: true, Tools: true,
+		Pricing: Pricing{Input: 5, Output: 15}},
+	{ID: "gpt-4o-mini", Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutputTokens: 16384, Encoding: "o200k_base",
+		Vision: true, Tools: true, StructuredOutputs: true,
+		Pricing: Pricing{Input: 0.15, CachedInput: 0.075, Output: 0.6}},
+	{ID: "o1", Provider: ProviderOpenAI, ContextWindow: 200000, MaxOutputTokens: 100000, Encoding: "o200k_base",
+		Vision: true, Tools: true, StructuredOutputs: true, ReasoningEffort: true,
+		Pricing: Pricing{Input: 15, CachedInput: 7.5, Output: 60}},
+	{ID: "o3", Provider: ProviderOpenAI, ContextWindow: 200000, MaxOutputTokens: 100000, Encoding: "o200k_base",
+		Vision: true, Tools: true, StructuredOutputs: true, ReasoningEffort: true,
+		Pricing: Pricing{Input: 2, CachedInput: 0.5, Output: 8}},
+	{ID: "o3-mini", Provider: ProviderOpenAI, ContextWindow: 200000, MaxOutputTokens: 100000, Encoding: "o200k_base",
+		Tools: true, StructuredOutputs: true, ReasoningEffort: true,
+		Pricing: Pricing{Input: 1.1, CachedInput: 0.55, Output: 4.4}},
+	{ID: "o4-mini", Provider: ProviderOpenAI, ContextWindow: 200000, MaxOutputTokens: 100000, Encoding: "o200k_base",
+		Vision: true, Tools: true, StructuredOutputs: true, ReasoningEffort: true,
+		Pricing: Pricing{Input: 1.1, CachedInput: 0.275, Output: 4.4}},
+	{ID: "gpt-4-turbo", Provider: ProviderOpenAI, ContextWindow: 128000, MaxOutputTokens: 4096, Encoding: "cl100k_base",
+		Vision: true, Tools: true,
+		Pricing: Pricing{Input: 10, Output: 30}},
+	{ID: "gpt-4", Provider: ProviderOpenAI, ContextWindow: 8192, MaxOutputTokens: 8192, Encoding: "cl100k_base",
+		Tools:   true,
+		Pricing: Pricing{Input: 30, Output: 60}},
+	{ID: "gpt-3.5-turbo", Provider: ProviderOpenAI, ContextWindow: 16385, MaxOutputTokens: 4096, Encoding: "cl100k_base",
+		Tools:   true,
+		Pricing: Pricing{Input: 0.5, Output: 1.5}},
+
+	// Anthropic（CachedInput はキャッシュの読み込み、CacheWrite は5分間のキャッシュへの書き込みの料金）
+	{ID: "claude-opus-4-1", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 32000,
+		Vision: true, Tools: true, StructuredOutputs: true,
+		Pricing: Pricing{Input: 15, CachedInput: 1.5, CacheWrite: 18.75, Output: 75}},
+	{ID: "claude-sonnet-4-5", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 64000,
+		Vision: true, Tools: true, StructuredOutputs: true,
+		Pricing: Pricing{Input: 3, CachedInput: 0.3, CacheWrite: 3.75, Output: 15}},
+	{ID: "claude-sonnet-4", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 64000,
+		Vision: true, Tools: true, StructuredOutputs: true,
+		Pricing: Pricing{Input: 3, CachedInput: 0.3, CacheWrite: 3.75, Output: 15}},
+	{ID: "claude-haiku-4-5", Provider: ProviderAnthropic, ContextWindow: 200000, MaxOutputTokens: 64000,
+		Vision: true, Tools: true, StructuredOutputs: true,
+		Pricing: Pricing{Input: 1, CachedInput: 0.1, CacheWrite: 1.25, Output: 5}},
+
+	// Gemini（200Kトークン以下のプロンプトの料金）
+	{ID: "gemini-2.5-pro", Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 65536,
+		Vision: true, Tools: true, StructuredOutputs: true, ReasoningEffort: true,
+		Pricing: Pricing{Input: 1.25, CachedInput: 0.31, Output: 10}},
+	{ID: "gemini-2.5-flash", Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 65536,
+		Vision: true, Tools: true, StructuredOutputs: true, ReasoningEffort: true,
+		Pricing: Pricing{Input: 0.3, CachedInput: 0.075, Output: 2.5}},
+	{ID: "gemini-2.0-flash", Provider: ProviderGemini, ContextWindow: 1048576, MaxOutputTokens: 8192,
+		Vision: true, Tools: true, StructuredOutputs: true,
+		Pricing: Pricing{Input: 0.1, CachedInput: 0.025, Output: 0.4}},
+}
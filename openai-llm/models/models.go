@@ -0,0 +1,214 @@
+// Package models はモデルごとの機能、トークンの上限、料金をまとめたレジストリを提供します
+//
+// 組み込みの値は各社の公開情報に基づきますが、設定ファイルで追加や上書きができます。
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// プロバイダー名
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderGemini    = "gemini"
+)
+
+// DefaultModel はOpenAIのクライアントが使用するデフォルトのモデルです
+const DefaultModel = "gpt-4o-2024-08-06"
+
+// ErrUnsupported はモデルが対応していない機能を要求した場合のエラーです
+var ErrUnsupported = errors.New("model does not support the requested feature")
+
+// Model はモデルの機能と上限、料金を表します
+type Model struct {
+	ID       string `json:"id"`
+	Provider string `json:"provider"`
+	// ContextWindow は入力と出力を合わせたトークン数の上限です
+	ContextWindow int `json:"context_window"`
+	// MaxOutputTokens は1回の応答で生成できるトークン数の上限です
+	MaxOutputTokens int `json:"max_output_tokens"`
+	// Encoding はトークン数を数えるためのエンコーディングです（例: o200k_base）
+	Encoding string `json:"encoding,omitempty"`
+	// Vision は画像の入力に対応しているかを表します
+	Vision bool `json:"vision"`
+	// Tools はツール（Function Calling）に対応しているかを表します
+	Tools bool `json:"tools"`
+	// StructuredOutputs は response_format の json_schema に対応しているかを表します
+	StructuredOutputs bool `json:"structured_outputs"`
+	// ReasoningEffort は reasoning_effort の指定に対応しているかを表します
+	ReasoningEffort bool    `json:"reasoning_effort"`
+	Pricing         Pricing `json:"pricing"`
+}
+
+// Pricing は100万トークンあたりの料金（USD）です
+type Pricing struct {
+	Input float64 `json:"input"`
+	// CachedInput はキャッシュされた入力トークンの料金です（0の場合は Input と同じ）
+	CachedInput float64 `json:"cached_input,omitempty"`
+	// CacheWrite はキャッシュに書き込む入力トークンの料金です（0の場合は Input と同じ）
+	CacheWrite float64 `json:"cache_write,omitempty"`
+	Output     float64 `json:"output"`
+}
+
+// PromptBudget は応答のために reserve トークンを確保した場合に、プロンプトに使えるトークン数を返します
+// reserve が0の場合は MaxOutputTokens を確保します
+func (m Model) PromptBudget(reserve int) int {
+	if reserve <= 0 {
+		reserve = m.MaxOutputTokens
+	}
+	return max(m.ContextWindow-reserve, 0)
+}
+
+// Requirements はリクエストが必要とする機能です
+type Requirements struct {
+	Vision            bool
+	Tools             bool
+	StructuredOutputs bool
+	ReasoningEffort   bool
+	// MaxOutputTokens は要求した出力トークン数の上限です（0の場合は確認しません）
+	MaxOutputTokens int
+}
+
+// Check はモデルが要求された機能に対応しているかを確認します
+// 対応していない場合は ErrUnsupported をラップしたエラーを返します
+func (m Model) Check(req Requirements) error {
+	var unsupported []string
+	if req.Vision && !m.Vision {
+		unsupported = append(unsupported, "image input")
+	}
+	if req.Tools && !m.Tools {
+		unsupported = append(unsupported, "tools")
+	}
+	if req.StructuredOutputs && !m.StructuredOutputs {
+		unsupported = append(unsupported, "structured outputs")
+	}
+	if req.ReasoningEffort && !m.ReasoningEffort {
+		unsupported = append(unsupported, "reasoning_effort")
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%w: %s does not support %s", ErrUnsupported, m.ID, strings.Join(unsupported, ", "))
+	}
+	if req.MaxOutputTokens > 0 && m.MaxOutputTokens > 0 && req.MaxOutputTokens > m.MaxOutputTokens {
+		return fmt.Errorf("%w: %s generates at most %d tokens, got max_completion_tokens %d", ErrUnsupported, m.ID, m.MaxOutputTokens, req.MaxOutputTokens)
+	}
+	return nil
+}
+
+// Registry はモデルIDからModelを引くためのレジストリです
+type Registry struct {
+	mu     sync.RWMutex
+	models map[string]Model
+}
+
+// NewRegistry は指定したモデルを登録したRegistryを作成します
+func NewRegistry(models ...Model) *Registry {
+	r := &Registry{models: make(map[string]Model, len(models))}
+	for _, m := range models {
+		r.models[m.ID] = m
+	}
+	return r
+}
+
+var defaultRegistry = NewRegistry(builtin...)
+
+// Default は組み込みのモデルを登録したRegistryを返します
+// LoadFile で設定を読み込むと、このRegistryを使用する全てのクライアントに反映されます
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register はモデルを登録します（同じIDのモデルは上書きされます）
+func (r *Registry) Register(m Model) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[m.ID] = m
+}
+
+// snapshotSuffix は日付付きのスナップショットの接尾辞（-2024-11-20、-20250514、-0613）に一致します
+var snapshotSuffix = regexp.MustCompile(`-(\d{4}-\d{2}-\d{2}|\d{8}|\d{4})$`)
+
+// Lookup はモデルIDに対応するModelを返します
+// 完全に一致するものがない場合は、日付付きのスナップショット（gpt-4o-2024-11-20 など）や
+// ファインチューニングしたモデル（ft:gpt-4o-mini:...）を元の登録済みのIDとして扱います
+// gpt-4-vision-preview や o1-mini のように機能や上限が異なり得る派生モデルは、登録されていなければ見つかりません
+func (r *Registry) Lookup(id string) (Model, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if m, ok := r.models[id]; ok {
+		return m, true
+	}
+
+	base := strings.TrimPrefix(id, "ft:")
+	base, _, _ = strings.Cut(base, ":")
+	m, ok := r.models[base]
+	if !ok {
+		m, ok = r.models[snapshotSuffix.ReplaceAllString(base, "")]
+	}
+	if !ok {
+		return Model{}, false
+	}
+	m.ID = id
+	return m, true
+}
+
+// Models は登録済みのモデルをID順に返します
+func (r *Registry) Models() []Model {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	models := make([]Model, 0, len(r.models))
+	for _, m := range r.models {
+		models = append(models, m)
+	}
+	slices.SortFunc(models, func(a, b Model) int { return strings.Compare(a.ID, b.ID) })
+	return models
+}
+
+// Load はJSONのモデルの配列を読み込み、登録します
+// 既に登録されているIDは、設定ファイルに書かれた項目だけが上書きされます
+//
+//	[{"id": "gpt-4o", "pricing": {"input": 2.5, "output": 10}}, {"id": "my-model", "context_window": 32768}]
+func (r *Registry) Load(reader io.Reader) error {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(reader).Decode(&raw); err != nil {
+		return fmt.Errorf("error parsing model registry: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, data := range raw {
+		var header struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return fmt.Errorf("error parsing model %d: %v", i, err)
+		}
+		if header.ID == "" {
+			return fmt.Errorf("model %d has no id", i)
+		}
+		m := r.models[header.ID]
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("error parsing model %s: %v", header.ID, err)
+		}
+		r.models[m.ID] = m
+	}
+	return nil
+}
+
+// LoadFile は設定ファイルからモデルを読み込みます
+func (r *Registry) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening model registry: %v", err)
+	}
+	defer f.Close()
+	return r.Load(f)
+}
@@ -0,0 +1,129 @@
+package models_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/models"
+)
+
+func TestLookup(t *testing.T) {
+	r := models.Default()
+	tests := []struct {
+		id       string
+		expected string
+		found    bool
+	}{
+		{"gpt-4o", "gpt-4o", true},
+		{"gpt-4o-2024-11-20", "gpt-4o", true},
+		{"gpt-4o-mini-2024-07-18", "gpt-4o-mini", true},
+		{"gpt-4o-2024-05-13", "gpt-4o-2024-05-13", true},
+		{"ft:gpt-4o-mini-2024-07-18:org::abc123", "gpt-4o-mini", true},
+		{"gpt-4o-2024-08-06", "gpt-4o", true},
+		{"gpt-4-0613", "gpt-4", true},
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4-5", true},
+		{"llama3.1", "", false},
+		{"gpt-4omni", "", false},
+		{"gpt-4-vision-preview", "", false},
+		{"gpt-4-1106-preview", "", false},
+		{"o1-mini", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			m, ok := r.Lookup(tt.id)
+			if ok != tt.found {
+				t.Fatalf("expected found=%v, got %v", tt.found, ok)
+			}
+			if !ok {
+				return
+			}
+			if m.ID != tt.id {
+				t.Errorf("expected returned ID %q, got %q", tt.id, m.ID)
+			}
+			base, _ := r.Lookup(tt.expected)
+			if m.ContextWindow != base.ContextWindow || m.Pricing != base.Pricing {
+				t.Errorf("expected %s to resolve to %s, got %+v", tt.id, tt.expected, m)
+			}
+		})
+	}
+}
+
+func TestDefaultModelIsRegistered(t *testing.T) {
+	m, ok := models.Default().Lookup(models.DefaultModel)
+	if !ok || !m.Vision || !m.Tools || !m.StructuredOutputs || m.Encoding == "" {
+		t.Errorf("expected default model to support vision, tools and structured outputs, got %+v (found=%v)", m, ok)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	r := models.Default()
+	textOnly, _ := r.Lookup("gpt-3.5-turbo")
+	err := textOnly.Check(models.Requirements{Vision: true, StructuredOutputs: true})
+	if !errors.Is(err, models.ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "image input") || !strings.Contains(err.Error(), "structured outputs") {
+		t.Errorf("expected error to name both features, got %v", err)
+	}
+
+	gpt4o, _ := r.Lookup("gpt-4o")
+	if err := gpt4o.Check(models.Requirements{Vision: true, Tools: true, StructuredOutputs: true}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := gpt4o.Check(models.Requirements{ReasoningEffort: true}); !errors.Is(err, models.ErrUnsupported) {
+		t.Errorf("expected reasoning_effort to be rejected for gpt-4o, got %v", err)
+	}
+	if err := gpt4o.Check(models.Requirements{MaxOutputTokens: gpt4o.MaxOutputTokens + 1}); !errors.Is(err, models.ErrUnsupported) {
+		t.Errorf("expected max output tokens over the limit to be rejected, got %v", err)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	r := models.NewRegistry(models.Model{
+		ID: "gpt-4o", Provider: models.ProviderOpenAI, ContextWindow: 128000, MaxOutputTokens: 16384,
+		Vision: true, Tools: true, Pricing: models.Pricing{Input: 2.5, Output: 10},
+	})
+	path := filepath.Join(t.TempDir(), "models.json")
+	config := `[
+		{"id": "gpt-4o", "pricing": {"input": 1, "output": 4}},
+		{"id": "my-local-model", "context_window": 32768, "tools": true}
+	]`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.LoadFile(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m, _ := r.Lookup("gpt-4o")
+	if m.Pricing.Input != 1 || m.Pricing.Output != 4 {
+		t.Errorf("expected pricing to be overridden, got %+v", m.Pricing)
+	}
+	if m.ContextWindow != 128000 || !m.Vision {
+		t.Errorf("expected unspecified fields to be kept, got %+v", m)
+	}
+	local, ok := r.Lookup("my-local-model")
+	if !ok || local.ContextWindow != 32768 || !local.Tools || local.Vision {
+		t.Errorf("expected new model to be registered, got %+v (found=%v)", local, ok)
+	}
+	if got := len(r.Models()); got != 2 {
+		t.Errorf("expected 2 models, got %d", got)
+	}
+
+	if err := r.Load(strings.NewReader(`[{"context_window": 1}]`)); err == nil {
+		t.Error("expected error for model without id")
+	}
+}
+
+func TestPromptBudget(t *testing.T) {
+	m := models.Model{ContextWindow: 128000, MaxOutputTokens: 16384}
+	if got := m.PromptBudget(0); got != 128000-16384 {
+		t.Errorf("expected budget to reserve max output tokens, got %d", got)
+	}
+	if got := m.PromptBudget(1000); got != 127000 {
+		t.Errorf("expected budget 127000, got %d", got)
+	}
+}
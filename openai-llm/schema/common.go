@@ -7,5 +7,8 @@ const (
 	SchemaTypeArray    SchemaType = "array"
 	SchemaTypeString   SchemaType = "string"
 	SchemaTypeNumber   SchemaType = "number"
+	SchemaTypeInteger  SchemaType = "integer"
+	SchemaTypeBoolean  SchemaType = "boolean"
+	SchemaTypeNull     SchemaType = "null"
 	SchemaTypeFunction SchemaType = "function"
 )
@@ -4,21 +4,23 @@ package schema
 type WeatherSchema struct {
 	Name   string     `json:"name"`
 	Schema BaseSchema `json:"schema"`
+	Strict bool       `json:"strict"`
 }
 
 // WeatherResponse は天気情報のレスポンスを定義します
 type WeatherResponse struct {
-	Location    string  `json:"location"`
-	Temperature float64 `json:"temperature"`
-	Unit        string  `json:"unit"`
-	Conditions  string  `json:"conditions"`
+	Location    string  `json:"location" jsonschema:"description=Location for weather information"`
+	Temperature float64 `json:"temperature" jsonschema:"description=Current temperature"`
+	Unit        string  `json:"unit" jsonschema:"description=Temperature unit,enum=C|F"`
+	Conditions  string  `json:"conditions" jsonschema:"description=Current weather conditions"`
 }
 
 // NewWeatherSchema は新しいWeatherSchemaを作成します
 func NewWeatherSchema() *WeatherSchema {
 	falseValue := false
 	return &WeatherSchema{
-		Name: "weather_response",
+		Name:   "weather_response",
+		Strict: true,
 		Schema: BaseSchema{
 			Type: "object",
 			Properties: map[string]SchemaProperty{
@@ -50,13 +52,14 @@ func NewWeatherSchema() *WeatherSchema {
 type ImageAnalysisSchema struct {
 	Name   string     `json:"name"`
 	Schema BaseSchema `json:"schema"`
+	Strict bool       `json:"strict"`
 }
 
 // ImageAnalysisResponse は画像分析のレスポンスを定義します
 type ImageAnalysisResponse struct {
-	Category    string `json:"category"`
-	Description string `json:"description"`
-	Objects     string `json:"objects"`
+	Category    string `json:"category" jsonschema:"description=Category of the image scene (e.g., landscape, cityscape, indoor)"`
+	Description string `json:"description" jsonschema:"description=Detailed description of the image content"`
+	Objects     string `json:"objects" jsonschema:"description=Comma-separated list of detected objects in the image (e.g., tree,mountain,sky)"`
 }
 
 const ImageAnalysisPrompt = `Please analyze the image and provide information in the following format:
@@ -81,7 +84,8 @@ Please ensure all observations are objective and focus on visible elements in th
 func NewImageAnalysisSchema() *ImageAnalysisSchema {
 	falseValue := false
 	return &ImageAnalysisSchema{
-		Name: "image_analysis_response",
+		Name:   "image_analysis_response",
+		Strict: true,
 		Schema: BaseSchema{
 			Type: "object",
 			Properties: map[string]SchemaProperty{
@@ -110,17 +114,18 @@ func NewImageAnalysisSchema() *ImageAnalysisSchema {
 type ObjectAnalysisSchema struct {
 	Name   string     `json:"name"`
 	Schema BaseSchema `json:"schema"`
+	Strict bool       `json:"strict"`
 }
 
 // ObjectAnalysisResponse はオブジェクト分析のレスポンスを定義します
 type ObjectAnalysisResponse struct {
-	Objects []ObjectItem `json:"objects"`
+	Objects []ObjectItem `json:"objects" jsonschema:"description=List of objects detected in the image"`
 }
 
 // ObjectItem は分析された個別のオブジェクトを表す構造体です
 type ObjectItem struct {
-	Name     string `json:"name"`
-	Category string `json:"category"`
+	Name     string `json:"name" jsonschema:"description=Name of the identified object"`
+	Category string `json:"category" jsonschema:"description=Category of the identified object"`
 }
 
 const ObjectAnalysisPrompt = `Please analyze the objects in the image and provide information in the following format:
@@ -156,7 +161,8 @@ Please identify and list all distinct objects visible in the image.`
 func NewObjectAnalysisSchema() *ObjectAnalysisSchema {
 	falseValue := false
 	return &ObjectAnalysisSchema{
-		Name: "object_analysis_response",
+		Name:   "object_analysis_response",
+		Strict: true,
 		Schema: BaseSchema{
 			Type: "object",
 			Properties: map[string]SchemaProperty{
@@ -175,7 +181,8 @@ func NewObjectAnalysisSchema() *ObjectAnalysisSchema {
 								Description: "Category of the identified object",
 							},
 						},
-						Required: []string{"name", "category"},
+						Required:             []string{"name", "category"},
+						AdditionalProperties: &falseValue,
 					},
 				},
 			},
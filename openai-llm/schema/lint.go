@@ -0,0 +1,193 @@
+package schema
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// strict モードでスキーマに課される上限です
+const (
+	maxStrictNesting    = 10
+	maxStrictProperties = 5000
+	maxStrictEnumValues = 1000
+)
+
+// strictTypes は strict モードで使用できる type です
+var strictTypes = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+// LintIssue はOpenAIのstrictモードで受け付けられないスキーマの箇所を表します
+type LintIssue struct {
+	// Path はスキーマ内の位置です（例: #/properties/objects/items）
+	Path string
+	// Rule は違反した規則です（type / required / additionalProperties / items / $ref / nesting / properties / enum）
+	Rule string
+	// Message は違反の内容です
+	Message string
+	// Fixable は Fix で自動的に修正できるかを表します
+	Fixable bool
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// Lint はスキーマがOpenAIのstrictモードの規則に従っているかを検査し、違反した箇所を全て返します
+//
+// 全てのプロパティが required に含まれていること、全てのオブジェクトに additionalProperties: false が
+// 設定されていること、配列に items があること、$ref が解決できること、ネストの深さやプロパティ数が
+// 上限以内であることを検査します。
+func Lint(s BaseSchema) []LintIssue {
+	l := &linter{defs: s.Defs}
+	l.lint(s)
+	return l.issues
+}
+
+// Fix は Lint が報告する違反のうち修正できるものを修正したスキーマと、修正できなかった違反を返します
+//
+// additionalProperties は false に設定され、required に含まれないプロパティは required に追加した上で
+// null を許容する型に変更されます（省略可能なフィールドは null で表現されます）。
+// 存在しないプロパティを指す required の要素は削除されます。引数のスキーマは変更されません。
+func Fix(s BaseSchema) (BaseSchema, []LintIssue) {
+	l := &linter{defs: s.Defs, fix: true}
+	fixed := l.lint(s)
+	return fixed, l.issues
+}
+
+// linter はスキーマを走査して違反を集め、fix が true の場合は修正したコピーを組み立てます
+type linter struct {
+	defs       map[string]SchemaProperty
+	fix        bool
+	properties int
+	issues     []LintIssue
+}
+
+func (l *linter) add(path, rule string, fixable bool, format string, args ...any) {
+	if l.fix && fixable {
+		return
+	}
+	l.issues = append(l.issues, LintIssue{Path: path, Rule: rule, Message: fmt.Sprintf(format, args...), Fixable: fixable})
+}
+
+func (l *linter) lint(s BaseSchema) BaseSchema {
+	if s.Type != "object" {
+		l.add("#", "type", false, "root schema must be an object, got %q", s.Type)
+	}
+	root := l.property(SchemaProperty{
+		Type:                 s.Type,
+		Properties:           s.Properties,
+		Required:             s.Required,
+		AdditionalProperties: s.AdditionalProperties,
+	}, "#", 0)
+
+	fixed := BaseSchema{
+		Type:                 s.Type,
+		Properties:           root.Properties,
+		Required:             root.Required,
+		AdditionalProperties: root.AdditionalProperties,
+	}
+	if s.Defs != nil {
+		fixed.Defs = make(map[string]SchemaProperty, len(s.Defs))
+		for _, name := range slices.Sorted(maps.Keys(s.Defs)) {
+			fixed.Defs[name] = l.property(s.Defs[name], "#/$defs/"+name, 0)
+		}
+	}
+
+	if l.properties > maxStrictProperties {
+		l.add("#", "properties", false, "schema has %d properties, the limit is %d", l.properties, maxStrictProperties)
+	}
+	return fixed
+}
+
+// property はプロパティを検査し、修正したコピーを返します
+func (l *linter) property(p SchemaProperty, path string, depth int) SchemaProperty {
+	if p.Ref != "" {
+		if !l.resolves(p.Ref) {
+			l.add(path, "$ref", false, "unresolvable reference %s", p.Ref)
+		}
+		return p
+	}
+
+	if len(p.AnyOf) > 0 {
+		branches := make([]SchemaProperty, len(p.AnyOf))
+		for i, branch := range p.AnyOf {
+			branches[i] = l.property(branch, fmt.Sprintf("%s/anyOf/%d", path, i), depth)
+		}
+		p.AnyOf = branches
+		return p
+	}
+
+	if !slices.Contains(strictTypes, p.Type) {
+		l.add(path, "type", false, "unsupported type %q", p.Type)
+		return p
+	}
+	if len(p.Enum) > maxStrictEnumValues {
+		l.add(path, "enum", false, "enum has %d values, the limit is %d", len(p.Enum), maxStrictEnumValues)
+	}
+
+	switch p.Type {
+	case "object":
+		return l.object(p, path, depth)
+	case "array":
+		if p.Items == nil {
+			l.add(path, "items", false, "array schema requires items")
+			return p
+		}
+		items := l.property(*p.Items, path+"/items", depth)
+		p.Items = &items
+	}
+	return p
+}
+
+// object はオブジェクトの required と additionalProperties を検査します
+func (l *linter) object(p SchemaProperty, path string, depth int) SchemaProperty {
+	if depth+1 > maxStrictNesting {
+		l.add(path, "nesting", false, "objects are nested more than %d levels deep", maxStrictNesting)
+	}
+	if p.AdditionalProperties == nil || *p.AdditionalProperties {
+		l.add(path, "additionalProperties", true, "additionalProperties must be false")
+		falseValue := false
+		p.AdditionalProperties = &falseValue
+	}
+
+	required := make([]string, 0, len(p.Properties))
+	for _, name := range p.Required {
+		if _, ok := p.Properties[name]; !ok {
+			l.add(path+"/required", "required", true, "required property %q is not defined", name)
+			continue
+		}
+		required = append(required, name)
+	}
+
+	properties := make(map[string]SchemaProperty, len(p.Properties))
+	for _, name := range slices.Sorted(maps.Keys(p.Properties)) {
+		l.properties++
+		prop := l.property(p.Properties[name], path+"/properties/"+name, depth+1)
+		if !slices.Contains(p.Required, name) {
+			l.add(path+"/properties/"+name, "required", true, "property %q must be listed in required", name)
+			prop.Nullable = true
+			required = append(required, name)
+		}
+		properties[name] = prop
+	}
+
+	if p.Properties != nil {
+		p.Properties = properties
+	}
+	p.Required = required
+	return p
+}
+
+// resolves は $ref の参照先が存在するかを返します
+func (l *linter) resolves(ref string) bool {
+	if ref == "#" {
+		return true
+	}
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return false
+	}
+	_, ok = l.defs[name]
+	return ok
+}
@@ -0,0 +1,104 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+)
+
+func TestLintBuiltInSchemas(t *testing.T) {
+	schemas := map[string]schema.BaseSchema{
+		"weather":          schema.NewWeatherSchema().Schema,
+		"image":            schema.NewImageAnalysisSchema().Schema,
+		"object":           schema.NewObjectAnalysisSchema().Schema,
+		"weather function": schema.NewWeatherFunctionCallSchema().Function.Parameters,
+		"profile":          schema.MustFrom[profile](),
+		"tree":             schema.MustFrom[tree](),
+	}
+	for name, s := range schemas {
+		if issues := schema.Lint(s); len(issues) != 0 {
+			t.Errorf("%s: expected no issues, got %v", name, issues)
+		}
+	}
+
+	if !schema.NewWeatherSchema().Strict || !schema.NewImageAnalysisSchema().Strict || !schema.NewObjectAnalysisSchema().Strict {
+		t.Error("expected built-in response schemas to be strict")
+	}
+	if !schema.NewWeatherFunctionCallSchema().Function.Strict {
+		t.Error("expected weather function to be strict")
+	}
+}
+
+func TestLint(t *testing.T) {
+	trueValue := true
+	s := schema.BaseSchema{
+		Type: "object",
+		Properties: map[string]schema.SchemaProperty{
+			"objects": {
+				Type: "array",
+				Items: &schema.SchemaProperty{
+					Type: "object",
+					Properties: map[string]schema.SchemaProperty{
+						"name":     {Type: "string"},
+						"category": {Type: "string"},
+					},
+					Required: []string{"name"},
+				},
+			},
+			"list":  {Type: "array"},
+			"other": {Ref: "#/$defs/missing"},
+			"extra": {Type: "object", Properties: map[string]schema.SchemaProperty{}, AdditionalProperties: &trueValue},
+		},
+		Required: []string{"objects", "list", "other", "extra", "gone"},
+	}
+
+	got := map[string]string{}
+	for _, issue := range schema.Lint(s) {
+		got[issue.Path] = issue.Rule
+	}
+	want := map[string]string{
+		"#":                          "additionalProperties",
+		"#/required":                 "required",
+		"#/properties/objects/items": "additionalProperties",
+		"#/properties/objects/items/properties/category": "required",
+		"#/properties/list":  "items",
+		"#/properties/other": "$ref",
+		"#/properties/extra": "additionalProperties",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected issues\nwant: %v\ngot:  %v", want, got)
+	}
+
+	fixed, remaining := schema.Fix(s)
+	if len(remaining) != 2 || remaining[0].Rule != "items" || remaining[1].Rule != "$ref" {
+		t.Errorf("expected only unfixable issues to remain, got %v", remaining)
+	}
+	for _, issue := range remaining {
+		if issue.Fixable {
+			t.Errorf("expected %v to be unfixable", issue)
+		}
+	}
+
+	items := fixed.Properties["objects"].Items
+	if items.AdditionalProperties == nil || *items.AdditionalProperties {
+		t.Errorf("expected additionalProperties false, got %+v", items)
+	}
+	if !reflect.DeepEqual(items.Required, []string{"name", "category"}) || !items.Properties["category"].Nullable {
+		t.Errorf("expected category to become required and nullable, got %+v", items)
+	}
+	if !reflect.DeepEqual(fixed.Required, []string{"objects", "list", "other", "extra"}) {
+		t.Errorf("expected undefined required property to be removed, got %v", fixed.Required)
+	}
+	data, _ := json.Marshal(fixed.Properties["objects"])
+	if want := `"category":{"type":["string","null"]}`; !strings.Contains(string(data), want) {
+		t.Errorf("expected %s in %s", want, data)
+	}
+
+	// 引数のスキーマは変更されない
+	if s.Properties["objects"].Items.AdditionalProperties != nil || len(s.Required) != 5 {
+		t.Errorf("expected original schema to be unchanged, got %+v", s)
+	}
+}
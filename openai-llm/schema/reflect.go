@@ -0,0 +1,348 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// From は構造体 T のフィールドと json タグからBaseSchemaを生成します
+//
+// 生成されるスキーマはOpenAIのstrictモードの規則に従い、全てのフィールドが required に含まれ、
+// 全てのオブジェクトに additionalProperties: false が設定されます。
+// ポインタ型のフィールドは ["string", "null"] のように null を許容する型として出力されます。
+// 再帰的な型は $defs に定義され、$ref で参照されます（T 自身への参照は "#" になります）。
+// 説明や列挙値、数値や配列の制約は jsonschema タグで指定できます。
+//
+//	Unit string `json:"unit" jsonschema:"description=Temperature unit,enum=C|F"`
+//	Age  int    `json:"age" jsonschema:"minimum=0,maximum=150"`
+func From[T any]() (BaseSchema, error) {
+	t := reflect.TypeFor[T]()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return BaseSchema{}, fmt.Errorf("schema: %s is not a struct", t)
+	}
+
+	g := &generator{
+		root:      t,
+		visiting:  make(map[reflect.Type]bool),
+		recursive: make(map[reflect.Type]bool),
+		defs:      make(map[string]SchemaProperty),
+	}
+	prop, err := g.object(t)
+	if err != nil {
+		return BaseSchema{}, err
+	}
+
+	s := BaseSchema{
+		Type:                 prop.Type,
+		Properties:           prop.Properties,
+		Required:             prop.Required,
+		AdditionalProperties: prop.AdditionalProperties,
+	}
+	if len(g.defs) > 0 {
+		s.Defs = g.defs
+	}
+	return s, nil
+}
+
+// MustFrom は From と同じですが、エラーの場合はpanicします
+func MustFrom[T any]() BaseSchema {
+	s, err := From[T]()
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// generator は型からSchemaPropertyを組み立てます
+type generator struct {
+	root reflect.Type
+	// visiting は組み立て中の構造体です（再帰の検出に使用します）
+	visiting map[reflect.Type]bool
+	// recursive は自身から参照されている構造体で、$defs に定義されます
+	recursive map[reflect.Type]bool
+	defs      map[string]SchemaProperty
+}
+
+// property は型に対応するSchemaPropertyを返します
+func (g *generator) property(t reflect.Type) (SchemaProperty, error) {
+	if t.Kind() == reflect.Pointer {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		prop, err := g.property(t)
+		if err != nil {
+			return SchemaProperty{}, err
+		}
+		prop.Nullable = true
+		return prop, nil
+	}
+
+	switch t {
+	case timeType:
+		return SchemaProperty{Type: "string"}, nil
+	case rawMessageType:
+		return SchemaProperty{}, fmt.Errorf("schema: json.RawMessage has no fixed schema")
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return SchemaProperty{Type: "string"}, nil
+	case reflect.Bool:
+		return SchemaProperty{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return SchemaProperty{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return SchemaProperty{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		// []byte は encoding/json でbase64文字列として扱われる
+		if t.Elem().Kind() == reflect.Uint8 {
+			return SchemaProperty{Type: "string"}, nil
+		}
+		items, err := g.property(t.Elem())
+		if err != nil {
+			return SchemaProperty{}, err
+		}
+		return SchemaProperty{Type: "array", Items: &items}, nil
+	case reflect.Struct:
+		return g.object(t)
+	default:
+		return SchemaProperty{}, fmt.Errorf("schema: unsupported type %s", t)
+	}
+}
+
+// object は構造体をオブジェクト型のSchemaPropertyに変換します
+// 再帰的に参照される構造体は $defs に定義し、$ref を返します
+func (g *generator) object(t reflect.Type) (SchemaProperty, error) {
+	if g.visiting[t] {
+		if t == g.root {
+			return SchemaProperty{Ref: "#"}, nil
+		}
+		g.recursive[t] = true
+		return SchemaProperty{Ref: defRef(t)}, nil
+	}
+	if _, ok := g.defs[defName(t)]; ok && g.recursive[t] {
+		return SchemaProperty{Ref: defRef(t)}, nil
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	falseValue := false
+	prop := SchemaProperty{
+		Type:                 "object",
+		Properties:           map[string]SchemaProperty{},
+		Required:             []string{},
+		AdditionalProperties: &falseValue,
+	}
+	if err := g.fields(t, &prop); err != nil {
+		return SchemaProperty{}, err
+	}
+	if g.recursive[t] {
+		if existing, ok := g.defs[defName(t)]; ok && !reflect.DeepEqual(existing, prop) {
+			return SchemaProperty{}, fmt.Errorf("schema: conflicting definitions for %s", t)
+		}
+		g.defs[defName(t)] = prop
+		return SchemaProperty{Ref: defRef(t)}, nil
+	}
+	return prop, nil
+}
+
+// defRef は構造体の $defs への参照を返します
+func defRef(t reflect.Type) string {
+	return "#/$defs/" + defName(t)
+}
+
+// invalidDefName は $defs の名前に使用できない文字の並びに一致します
+var invalidDefName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// defName は構造体の $defs での名前を返します
+// 別のパッケージの同名の型と衝突しないようパッケージパスを含め、使用できない文字は "_" に置き換えます
+func defName(t reflect.Type) string {
+	return strings.Trim(invalidDefName.ReplaceAllString(t.PkgPath()+"."+t.Name(), "_"), "_")
+}
+
+// fields は構造体のフィールドをプロパティとして追加します
+// 埋め込み構造体のフィールドは encoding/json と同様に展開されます
+func (g *generator) fields(t reflect.Type, prop *SchemaProperty) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		jsonTag := field.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(jsonTag, ",")
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := g.fields(ft, prop); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldProp, err := g.property(field.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+		if err := applyTag(&fieldProp, field.Tag.Get("jsonschema")); err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+
+		if _, exists := prop.Properties[name]; !exists {
+			prop.Required = append(prop.Required, name)
+		}
+		prop.Properties[name] = fieldProp
+	}
+	return nil
+}
+
+// tagKeys は jsonschema タグで指定できるキーです
+var tagKeys = map[string]bool{
+	"title":            true,
+	"description":      true,
+	"enum":             true,
+	"const":            true,
+	"pattern":          true,
+	"format":           true,
+	"minimum":          true,
+	"maximum":          true,
+	"exclusiveMinimum": true,
+	"exclusiveMaximum": true,
+	"multipleOf":       true,
+	"minItems":         true,
+	"maxItems":         true,
+}
+
+// parseTag は jsonschema タグをキーと値に分解します
+// 説明文にカンマを含められるよう、既知のキーで始まらない部分は直前の値の続きとして扱います
+func parseTag(tag string) (map[string]string, error) {
+	options := map[string]string{}
+	if tag == "" {
+		return options, nil
+	}
+
+	var current string
+	for _, part := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if ok && tagKeys[key] {
+			current = key
+			options[key] = value
+			continue
+		}
+		if current == "" {
+			return nil, fmt.Errorf("unknown jsonschema tag option %q", part)
+		}
+		options[current] += "," + part
+	}
+	return options, nil
+}
+
+// applyTag は jsonschema タグの内容をプロパティに反映します
+func applyTag(prop *SchemaProperty, tag string) error {
+	options, err := parseTag(tag)
+	if err != nil {
+		return err
+	}
+	if title, ok := options["title"]; ok {
+		prop.Title = title
+	}
+	if description, ok := options["description"]; ok {
+		prop.Description = description
+	}
+
+	// 配列では値に対する制約を要素（items）に適用する
+	item := prop
+	for item.Type == "array" && item.Items != nil {
+		item = item.Items
+	}
+	if enum, ok := options["enum"]; ok {
+		item.Enum = strings.Split(enum, "|")
+	}
+	if value, ok := options["const"]; ok {
+		if item.Const, err = constValue(item.Type, value); err != nil {
+			return err
+		}
+	}
+	if pattern, ok := options["pattern"]; ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		item.Pattern = pattern
+	}
+	if format, ok := options["format"]; ok {
+		item.Format = format
+	}
+
+	for key, target := range map[string]**float64{
+		"minimum":          &item.Minimum,
+		"maximum":          &item.Maximum,
+		"exclusiveMinimum": &item.ExclusiveMinimum,
+		"exclusiveMaximum": &item.ExclusiveMaximum,
+		"multipleOf":       &item.MultipleOf,
+	} {
+		if value, ok := options[key]; ok {
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", key, value, err)
+			}
+			*target = &n
+		}
+	}
+	for key, target := range map[string]**int{
+		"minItems": &prop.MinItems,
+		"maxItems": &prop.MaxItems,
+	} {
+		if value, ok := options[key]; ok {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid %s %q", key, value)
+			}
+			*target = &n
+		}
+	}
+	return nil
+}
+
+// constValue は const タグの値をプロパティの型に合わせて変換します
+func constValue(schemaType, value string) (any, error) {
+	switch schemaType {
+	case "integer", "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid const %q: %v", value, err)
+		}
+		return n, nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid const %q: %v", value, err)
+		}
+		return b, nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,322 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+)
+
+func TestFromMatchesHandWrittenSchemas(t *testing.T) {
+	weather, err := schema.From[schema.WeatherResponse]()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertSameSchema(t, schema.NewWeatherSchema().Schema, weather)
+
+	image, err := schema.From[schema.ImageAnalysisResponse]()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertSameSchema(t, schema.NewImageAnalysisSchema().Schema, image)
+
+	objects, err := schema.From[schema.ObjectAnalysisResponse]()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertSameSchema(t, schema.NewObjectAnalysisSchema().Schema, objects)
+}
+
+func assertSameSchema(t *testing.T, want, got schema.BaseSchema) {
+	t.Helper()
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	if string(wantJSON) != string(gotJSON) {
+		t.Errorf("schema mismatch\nwant: %s\ngot:  %s", wantJSON, gotJSON)
+	}
+}
+
+type address struct {
+	City string `json:"city"`
+}
+
+type base struct {
+	ID int64 `json:"id"`
+}
+
+type profile struct {
+	base
+	Name     string    `json:"name" jsonschema:"description=Full name, as written"`
+	Nickname *string   `json:"nickname,omitempty"`
+	Tags     []string  `json:"tags"`
+	Home     address   `json:"home"`
+	Previous []address `json:"previous"`
+	Active   bool      `json:"active"`
+	Score    float32   `json:"score"`
+	Ignored  string    `json:"-"`
+	internal string
+}
+
+func TestFromStrictModeRules(t *testing.T) {
+	s, err := schema.From[profile]()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantRequired := []string{"id", "name", "nickname", "tags", "home", "previous", "active", "score"}
+	if !reflect.DeepEqual(s.Required, wantRequired) {
+		t.Errorf("unexpected required fields: %v", s.Required)
+	}
+	if s.AdditionalProperties == nil || *s.AdditionalProperties {
+		t.Error("expected additionalProperties false on root")
+	}
+
+	if got := s.Properties["name"].Description; got != "Full name, as written" {
+		t.Errorf("unexpected description: %q", got)
+	}
+	if got := s.Properties["id"].Type; got != "integer" {
+		t.Errorf("expected integer for id, got %s", got)
+	}
+
+	home := s.Properties["home"]
+	if home.AdditionalProperties == nil || *home.AdditionalProperties || !reflect.DeepEqual(home.Required, []string{"city"}) {
+		t.Errorf("nested object does not follow strict rules: %+v", home)
+	}
+	items := s.Properties["previous"].Items
+	if items == nil || items.AdditionalProperties == nil || *items.AdditionalProperties {
+		t.Errorf("array items do not follow strict rules: %+v", items)
+	}
+
+	raw, err := json.Marshal(s.Properties["nickname"])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(raw), `"type":["string","null"]`) {
+		t.Errorf("expected nullable type for pointer field, got %s", raw)
+	}
+
+	var decoded schema.SchemaProperty
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded.Type != "string" || !decoded.Nullable {
+		t.Errorf("nullable type did not round-trip: %+v", decoded)
+	}
+}
+
+type node struct {
+	Name     string `json:"name"`
+	Children []node `json:"children"`
+}
+
+// nodeDef は node の $defs での名前です
+const nodeDef = "github_com_yuki5155_go-llms_openai-llm_schema_test_node"
+
+type tree struct {
+	Root   node  `json:"root"`
+	Parent *tree `json:"parent"`
+}
+
+func TestFromRecursiveTypes(t *testing.T) {
+	s, err := schema.From[tree]()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Properties["root"].Ref != "#/$defs/"+nodeDef {
+		t.Errorf("expected root to reference $defs, got %+v", s.Properties["root"])
+	}
+	if parent := s.Properties["parent"]; parent.Ref != "#" || !parent.Nullable {
+		t.Errorf("expected nullable root reference, got %+v", parent)
+	}
+	def, ok := s.Defs[nodeDef]
+	if !ok || def.Properties["children"].Items.Ref != "#/$defs/"+nodeDef {
+		t.Fatalf("expected recursive node definition, got %+v", s.Defs)
+	}
+
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{`"$defs":{"` + nodeDef + `":`, `"anyOf":[{"$ref":"#"},{"type":"null"}]`} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("expected %s in %s", want, raw)
+		}
+	}
+
+	violations, err := schema.ValidateJSON(s, []byte(`{"root":{"name":"a","children":[{"name":"b","children":[{"name":1,"children":[]}]}]},"parent":null}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(violations) != 1 || violations[0].Path != "$.root.children[0].children[0].name" {
+		t.Errorf("expected a single nested violation, got %v", violations)
+	}
+}
+
+type page[T any] struct {
+	Items []T      `json:"items"`
+	Next  *page[T] `json:"next"`
+}
+
+type pages struct {
+	Nodes page[node]   `json:"nodes"`
+	Names page[string] `json:"names"`
+}
+
+func TestFromGenericDefNames(t *testing.T) {
+	s, err := schema.From[pages]()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	valid := regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	for name := range s.Defs {
+		if !valid.MatchString(name) {
+			t.Errorf("invalid $defs name %q", name)
+		}
+	}
+	// page[node]、page[string]、node の3つが別々に定義される
+	if len(s.Defs) != 3 || s.Properties["nodes"].Ref == s.Properties["names"].Ref {
+		t.Errorf("expected distinct definitions per instantiation, got %v", slices.Collect(maps.Keys(s.Defs)))
+	}
+}
+
+func TestFromConstraintTags(t *testing.T) {
+	type event struct {
+		Kind  string    `json:"kind" jsonschema:"const=event,title=Kind"`
+		Code  string    `json:"code" jsonschema:"pattern=^[A-Z]{2,3}$"`
+		At    string    `json:"at" jsonschema:"format=date-time"`
+		Score float64   `json:"score" jsonschema:"minimum=0,maximum=1"`
+		Tags  []string  `json:"tags" jsonschema:"minItems=1,maxItems=3"`
+		Count int       `json:"count" jsonschema:"exclusiveMinimum=0,multipleOf=2"`
+		When  time.Time `json:"when"`
+	}
+	s, err := schema.From[event]()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p := s.Properties["code"]; p.Pattern != "^[A-Z]{2,3}$" {
+		t.Errorf("expected pattern with comma to be kept, got %q", p.Pattern)
+	}
+	if p := s.Properties["kind"]; p.Const != "event" || p.Title != "Kind" {
+		t.Errorf("unexpected kind: %+v", p)
+	}
+	if p := s.Properties["score"]; *p.Minimum != 0 || *p.Maximum != 1 {
+		t.Errorf("unexpected score: %+v", p)
+	}
+	if p := s.Properties["tags"]; *p.MinItems != 1 || *p.MaxItems != 3 {
+		t.Errorf("unexpected tags: %+v", p)
+	}
+
+	valid := `{"kind":"event","code":"JP","at":"2024-01-02T03:04:05Z","score":0.5,"tags":["a"],"count":4,"when":"2024-01-02T03:04:05Z"}`
+	if violations, err := schema.ValidateJSON(s, []byte(valid)); err != nil || len(violations) != 0 {
+		t.Errorf("expected valid data, got %v, %v", violations, err)
+	}
+
+	invalid := `{"kind":"other","code":"jp","at":"yesterday","score":1.5,"tags":[],"count":3,"when":"2024-01-02T03:04:05Z"}`
+	violations, err := schema.ValidateJSON(s, []byte(invalid))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := map[string]string{}
+	for _, v := range violations {
+		got[v.Path] = v.Keyword
+	}
+	want := map[string]string{
+		"$.kind":  "const",
+		"$.code":  "pattern",
+		"$.at":    "format",
+		"$.score": "maximum",
+		"$.tags":  "minItems",
+		"$.count": "multipleOf",
+	}
+	for path, keyword := range want {
+		if got[path] != keyword {
+			t.Errorf("expected %s violation at %s, got %v", keyword, path, violations)
+		}
+	}
+
+	if _, err := schema.From[struct {
+		Code string `json:"code" jsonschema:"pattern=("`
+	}](); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestFromSliceTagsApplyToItems(t *testing.T) {
+	s, err := schema.From[struct {
+		Colors []string `json:"colors" jsonschema:"enum=red|green,minItems=1"`
+		Codes  []string `json:"codes" jsonschema:"pattern=^[A-Z]+$"`
+		Kinds  []string `json:"kinds" jsonschema:"const=point"`
+	}]()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	colors := s.Properties["colors"]
+	if len(colors.Enum) != 0 || colors.Items == nil || !slices.Equal(colors.Items.Enum, []string{"red", "green"}) || *colors.MinItems != 1 {
+		t.Errorf("expected enum on items and minItems on the array, got %+v", colors)
+	}
+	if codes := s.Properties["codes"]; codes.Pattern != "" || codes.Items.Pattern != "^[A-Z]+$" {
+		t.Errorf("expected pattern on items, got %+v", codes)
+	}
+	if kinds := s.Properties["kinds"]; kinds.Const != nil || kinds.Items.Const != "point" {
+		t.Errorf("expected const on items, got %+v", kinds)
+	}
+	if issues := schema.Lint(s); len(issues) != 0 {
+		t.Errorf("expected a strict-compatible schema, got %v", issues)
+	}
+
+	violations, err := schema.ValidateJSON(s, []byte(`{"colors":["red","blue"],"codes":["ab"],"kinds":["point"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := map[string]string{}
+	for _, v := range violations {
+		got[v.Path] = v.Keyword
+	}
+	if got["$.colors[1]"] != "enum" || got["$.codes[0]"] != "pattern" || len(got) != 2 {
+		t.Errorf("unexpected violations: %v", violations)
+	}
+}
+
+func TestFromNullableEnum(t *testing.T) {
+	s, err := schema.From[struct {
+		Unit *string `json:"unit" jsonschema:"enum=C|F"`
+	}]()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := json.Marshal(s.Properties["unit"])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `{"type":["string","null"],"enum":["C","F",null]}`; string(data) != want {
+		t.Errorf("expected %s, got %s", want, data)
+	}
+
+	var decoded schema.SchemaProperty
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !decoded.Nullable || !slices.Equal(decoded.Enum, []string{"C", "F"}) {
+		t.Errorf("expected the round trip to keep enum and nullability, got %+v", decoded)
+	}
+	if violations, err := schema.ValidateJSON(s, []byte(`{"unit":null}`)); err != nil || len(violations) != 0 {
+		t.Errorf("expected null to be valid, got %v, %v", violations, err)
+	}
+}
+
+func TestFromRejectsUnsupportedTypes(t *testing.T) {
+	if _, err := schema.From[struct {
+		Values map[string]string `json:"values"`
+	}](); err == nil {
+		t.Error("expected error for map type")
+	}
+	if _, err := schema.From[string](); err == nil {
+		t.Error("expected error for non-struct type")
+	}
+}
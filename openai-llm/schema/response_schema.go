@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// ResponseSchema は response_format の json_schema に指定するスキーマを定義します
+type ResponseSchema struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Schema      BaseSchema `json:"schema"`
+	Strict      bool       `json:"strict"`
+}
+
+// NewResponseSchema は型 T から strict モードのResponseSchemaを作成します
+// 名前は型名をスネークケースに変換したものになります（例: WeatherResponse → weather_response）
+func NewResponseSchema[T any]() (*ResponseSchema, error) {
+	s, err := From[T]()
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseSchema{
+		Name:   schemaName(reflect.TypeFor[T]()),
+		Schema: s,
+		Strict: true,
+	}, nil
+}
+
+// schemaName は型名からOpenAIが受け付ける形式（英数字・_・-）のスキーマ名を作成します
+func schemaName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	name := t.Name()
+	// ジェネリック型の型引数は名前に含めない
+	name, _, _ = strings.Cut(name, "[")
+	if name == "" {
+		return "response"
+	}
+
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
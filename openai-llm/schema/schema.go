@@ -2,6 +2,7 @@ package schema
 
 import (
 	"encoding/json"
+	"fmt"
 )
 
 // BaseSchema は全てのスキーマに共通する基本構造を定義します
@@ -10,16 +11,129 @@ type BaseSchema struct {
 	Properties           map[string]SchemaProperty `json:"properties"`
 	Required             []string                  `json:"required"`
 	AdditionalProperties *bool                     `json:"additionalProperties"`
+	// Defs は $ref から "#/$defs/名前" の形式で参照される定義です
+	Defs map[string]SchemaProperty `json:"$defs,omitempty"`
 }
 
 // SchemaProperty はJSONスキーマのプロパティを表現します
 type SchemaProperty struct {
-	Type        string                    `json:"type"`
-	Description string                    `json:"description,omitempty"`
-	Enum        []string                  `json:"enum,omitempty"`
-	Items       *SchemaProperty           `json:"items,omitempty"`
-	Required    []string                  `json:"required,omitempty"`
-	Properties  map[string]SchemaProperty `json:"properties,omitempty"`
+	// Type は AnyOf や Ref を指定する場合は空にします
+	Type                 string                    `json:"type,omitempty"`
+	Title                string                    `json:"title,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Const                any                       `json:"const,omitempty"`
+	Items                *SchemaProperty           `json:"items,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Properties           map[string]SchemaProperty `json:"properties,omitempty"`
+	AdditionalProperties *bool                     `json:"additionalProperties,omitempty"`
+	// AnyOf はいずれかに一致すればよいスキーマです
+	AnyOf []SchemaProperty `json:"anyOf,omitempty"`
+	// Ref は "#/$defs/名前" または再帰的なルート参照の "#" です
+	Ref string `json:"$ref,omitempty"`
+	// 文字列の制約
+	Pattern string `json:"pattern,omitempty"`
+	// Format は date-time / date / time / duration / email / hostname / ipv4 / ipv6 / uuid のいずれかです
+	Format string `json:"format,omitempty"`
+	// 数値の制約
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+	MultipleOf       *float64 `json:"multipleOf,omitempty"`
+	// 配列の制約
+	MinItems *int `json:"minItems,omitempty"`
+	MaxItems *int `json:"maxItems,omitempty"`
+	// Nullable が true の場合、type は ["string", "null"] のような形式で出力されます
+	// Type が空の場合（AnyOf や Ref）は anyOf に {"type": "null"} を加えて出力されます
+	Nullable bool `json:"-"`
+}
+
+// MarshalJSON は Nullable の場合に type を null との組み合わせで出力します
+// enum がある場合は null も加えます（加えないと strict モードのモデルは null を出力できません）
+func (p SchemaProperty) MarshalJSON() ([]byte, error) {
+	type alias SchemaProperty
+	if !p.Nullable || (p.Type == "" && p.Ref == "" && len(p.AnyOf) == 0) {
+		return json.Marshal(alias(p))
+	}
+	var enum []any
+	if len(p.Enum) > 0 {
+		for _, value := range p.Enum {
+			enum = append(enum, value)
+		}
+		enum = append(enum, nil)
+	}
+	if p.Type == "" {
+		// $ref や anyOf は null を許容する分岐を anyOf に加える
+		branches := []SchemaProperty{{Ref: p.Ref}}
+		if p.Ref == "" {
+			branches = p.AnyOf[:len(p.AnyOf):len(p.AnyOf)]
+		}
+		nullable := p
+		nullable.Nullable = false
+		nullable.Ref = ""
+		nullable.AnyOf = append(branches, SchemaProperty{Type: "null"})
+		return json.Marshal(struct {
+			Enum []any `json:"enum,omitempty"`
+			alias
+		}{
+			Enum:  enum,
+			alias: alias(nullable),
+		})
+	}
+	return json.Marshal(struct {
+		Type []string `json:"type"`
+		Enum []any    `json:"enum,omitempty"`
+		alias
+	}{
+		Type:  []string{p.Type, "null"},
+		Enum:  enum,
+		alias: alias(p),
+	})
+}
+
+// UnmarshalJSON は type が文字列でも配列でも読み込めるようにします
+func (p *SchemaProperty) UnmarshalJSON(data []byte) error {
+	type alias SchemaProperty
+	raw := struct {
+		Type json.RawMessage `json:"type"`
+		Enum []*string       `json:"enum"`
+		*alias
+	}{alias: (*alias)(p)}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	// enum の null は Nullable として読み込む
+	p.Enum = nil
+	for _, value := range raw.Enum {
+		if value == nil {
+			p.Nullable = true
+			continue
+		}
+		p.Enum = append(p.Enum, *value)
+	}
+	if len(raw.Type) == 0 {
+		return nil
+	}
+
+	var single string
+	if err := json.Unmarshal(raw.Type, &single); err == nil {
+		p.Type = single
+		return nil
+	}
+
+	var types []string
+	if err := json.Unmarshal(raw.Type, &types); err != nil {
+		return fmt.Errorf("invalid schema type: %s", string(raw.Type))
+	}
+	for _, t := range types {
+		if t == "null" {
+			p.Nullable = true
+		} else {
+			p.Type = t
+		}
+	}
+	return nil
 }
 
 // RequestFormat はOpenAIへのリクエストのフォーマットを定義します
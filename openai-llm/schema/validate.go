@@ -0,0 +1,332 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"net/mail"
+	"net/netip"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Violation はスキーマに違反した箇所を表します
+type Violation struct {
+	// Path は違反した値の位置です（例: $.objects[0].name）
+	Path string
+	// Keyword は違反したスキーマのキーワードです（required / type / enum / additionalProperties など）
+	Keyword string
+	// Message は違反の内容です
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// Validate はJSONをデコードした値がスキーマに従っているかを検証し、違反した箇所を返します
+// value には json.Unmarshal で any にデコードした値を指定します
+func Validate(s BaseSchema, value any) []Violation {
+	root := SchemaProperty{
+		Type:                 s.Type,
+		Properties:           s.Properties,
+		Required:             s.Required,
+		AdditionalProperties: s.AdditionalProperties,
+	}
+	v := &validator{root: root, defs: s.Defs}
+	v.validate(root, value, "$")
+	return v.violations
+}
+
+// ValidateJSON はJSONをデコードしてからスキーマに従っているかを検証します
+func ValidateJSON(s BaseSchema, data []byte) ([]Violation, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return Validate(s, value), nil
+}
+
+// validator は $ref の参照先を解決しながら違反を集めます
+type validator struct {
+	root       SchemaProperty
+	defs       map[string]SchemaProperty
+	violations []Violation
+}
+
+func (v *validator) add(path, keyword, format string, args ...any) {
+	v.violations = append(v.violations, Violation{Path: path, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+}
+
+// resolve は $ref の参照先を返します
+func (v *validator) resolve(ref string) (SchemaProperty, bool) {
+	if ref == "#" {
+		return v.root, true
+	}
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return SchemaProperty{}, false
+	}
+	def, ok := v.defs[name]
+	return def, ok
+}
+
+// validate は値がプロパティのスキーマに従っているかを再帰的に検証します
+func (v *validator) validate(p SchemaProperty, value any, path string) {
+	if value == nil && p.Nullable {
+		return
+	}
+
+	if p.Ref != "" {
+		target, ok := v.resolve(p.Ref)
+		if !ok {
+			v.add(path, "$ref", "unresolvable reference %s", p.Ref)
+			return
+		}
+		v.validate(target, value, path)
+		return
+	}
+
+	if len(p.AnyOf) > 0 {
+		for _, branch := range p.AnyOf {
+			sub := &validator{root: v.root, defs: v.defs}
+			sub.validate(branch, value, path)
+			if len(sub.violations) == 0 {
+				return
+			}
+		}
+		v.add(path, "anyOf", "value does not match any of the allowed schemas")
+		return
+	}
+
+	if value == nil {
+		if p.Type != "" && p.Type != "null" {
+			v.add(path, "type", "expected %s, got null", p.Type)
+		}
+		return
+	}
+	if !matchesType(p.Type, value) {
+		v.add(path, "type", "expected %s, got %s", p.Type, typeName(value))
+		return
+	}
+
+	if len(p.Enum) > 0 && !slices.Contains(p.Enum, enumValue(value)) {
+		v.add(path, "enum", "value %s is not one of %s", enumValue(value), strings.Join(p.Enum, ", "))
+	}
+	if p.Const != nil && !equalJSON(p.Const, value) {
+		v.add(path, "const", "value must be %v", p.Const)
+	}
+
+	switch val := value.(type) {
+	case map[string]any:
+		v.object(p, val, path)
+	case []any:
+		v.array(p, val, path)
+	case string:
+		v.string(p, val, path)
+	default:
+		if n, ok := number(value); ok {
+			v.number(p, n, path)
+		}
+	}
+}
+
+func (v *validator) object(p SchemaProperty, value map[string]any, path string) {
+	for _, name := range p.Required {
+		if _, ok := value[name]; !ok {
+			v.add(path+"."+name, "required", "required field is missing")
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(value)) {
+		prop, ok := p.Properties[name]
+		if !ok {
+			if p.AdditionalProperties != nil && !*p.AdditionalProperties {
+				v.add(path+"."+name, "additionalProperties", "unexpected field")
+			}
+			continue
+		}
+		v.validate(prop, value[name], path+"."+name)
+	}
+}
+
+func (v *validator) array(p SchemaProperty, value []any, path string) {
+	if p.MinItems != nil && len(value) < *p.MinItems {
+		v.add(path, "minItems", "expected at least %d items, got %d", *p.MinItems, len(value))
+	}
+	if p.MaxItems != nil && len(value) > *p.MaxItems {
+		v.add(path, "maxItems", "expected at most %d items, got %d", *p.MaxItems, len(value))
+	}
+	if p.Items == nil {
+		return
+	}
+	for i, item := range value {
+		v.validate(*p.Items, item, path+"["+strconv.Itoa(i)+"]")
+	}
+}
+
+func (v *validator) string(p SchemaProperty, value string, path string) {
+	if p.Pattern != "" {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			v.add(path, "pattern", "invalid pattern %s: %v", p.Pattern, err)
+		} else if !re.MatchString(value) {
+			v.add(path, "pattern", "value %q does not match %s", value, p.Pattern)
+		}
+	}
+	if p.Format != "" && !matchesFormat(p.Format, value) {
+		v.add(path, "format", "value %q is not a valid %s", value, p.Format)
+	}
+}
+
+func (v *validator) number(p SchemaProperty, value float64, path string) {
+	if p.Minimum != nil && value < *p.Minimum {
+		v.add(path, "minimum", "value %v is less than %v", value, *p.Minimum)
+	}
+	if p.Maximum != nil && value > *p.Maximum {
+		v.add(path, "maximum", "value %v is greater than %v", value, *p.Maximum)
+	}
+	if p.ExclusiveMinimum != nil && value <= *p.ExclusiveMinimum {
+		v.add(path, "exclusiveMinimum", "value %v must be greater than %v", value, *p.ExclusiveMinimum)
+	}
+	if p.ExclusiveMaximum != nil && value >= *p.ExclusiveMaximum {
+		v.add(path, "exclusiveMaximum", "value %v must be less than %v", value, *p.ExclusiveMaximum)
+	}
+	if p.MultipleOf != nil && *p.MultipleOf > 0 {
+		if q := value / *p.MultipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.add(path, "multipleOf", "value %v is not a multiple of %v", value, *p.MultipleOf)
+		}
+	}
+}
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hostnamePattern = regexp.MustCompile(`^(?i:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?)(?:\.(?i:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?))*$`)
+	durationPattern = regexp.MustCompile(`^P(?:\d+W|(?:\d+Y)?(?:\d+M)?(?:\d+D)?(?:T(?:\d+H)?(?:\d+M)?(?:\d+(?:\.\d+)?S)?)?)$`)
+)
+
+// matchesFormat は文字列が format に一致するかを判定します（未知の format は常に一致します）
+func matchesFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse(time.DateOnly, value)
+		return err == nil
+	case "time":
+		for _, layout := range []string{"15:04:05Z07:00", "15:04:05.999999999Z07:00", time.TimeOnly} {
+			if _, err := time.Parse(layout, value); err == nil {
+				return true
+			}
+		}
+		return false
+	case "duration":
+		return value != "P" && !strings.HasSuffix(value, "T") && durationPattern.MatchString(value)
+	case "email":
+		addr, err := mail.ParseAddress(value)
+		return err == nil && addr.Address == value
+	case "hostname":
+		return len(value) <= 253 && hostnamePattern.MatchString(value)
+	case "ipv4":
+		addr, err := netip.ParseAddr(value)
+		return err == nil && addr.Is4()
+	case "ipv6":
+		addr, err := netip.ParseAddr(value)
+		return err == nil && addr.Is6()
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	default:
+		return true
+	}
+}
+
+// equalJSON は2つの値がJSONとして等しいかを判定します
+func equalJSON(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	var va, vb any
+	if json.Unmarshal(ja, &va) != nil || json.Unmarshal(jb, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// enumValue は enum と比較するための値の文字列表現を返します
+func enumValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// matchesType は値がJSONスキーマの type に一致するかを判定します（type が空の場合は常に一致します）
+func matchesType(schemaType string, value any) bool {
+	switch schemaType {
+	case "":
+		return true
+	case "null":
+		return value == nil
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := number(value)
+		return ok
+	case "integer":
+		n, ok := number(value)
+		return ok && n == math.Trunc(n)
+	default:
+		return false
+	}
+}
+
+// number は float64 または json.Number の値を数値として返します
+func number(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// typeName はデコードされた値のJSONでの型名を返します
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,154 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"maps"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+)
+
+func TestValidateJSON(t *testing.T) {
+	s := schema.MustFrom[schema.ObjectAnalysisResponse]()
+
+	violations, err := schema.ValidateJSON(s, []byte(`{"objects":[{"name":"tree","category":"plant"}]}`))
+	if err != nil || len(violations) != 0 {
+		t.Fatalf("expected valid data, got %v, %v", violations, err)
+	}
+
+	violations, err = schema.ValidateJSON(s, []byte(`{"objects":[{"name":"tree"},{"name":1,"category":"plant"}]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"$.objects[0].category": "required",
+		"$.objects[1].name":     "type",
+	}
+	if len(violations) != len(want) {
+		t.Fatalf("expected %d violations, got %v", len(want), violations)
+	}
+	for _, v := range violations {
+		if want[v.Path] != v.Keyword {
+			t.Errorf("unexpected violation: %+v", v)
+		}
+	}
+
+	if _, err := schema.ValidateJSON(s, []byte(`{`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+// validateTestSchema は検証のキーワードを一通り含むスキーマです
+const validateTestSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"unit": {"type": "string", "enum": ["C", "F"]},
+		"note": {"type": ["string", "null"]},
+		"count": {"type": "integer"},
+		"ratio": {"type": "number"},
+		"active": {"type": "boolean"},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"root": {"$ref": "#/$defs/node"}
+	},
+	"required": ["name", "unit", "note"],
+	"additionalProperties": false,
+	"$defs": {
+		"node": {
+			"type": "object",
+			"properties": {
+				"value": {"type": "integer"},
+				"children": {"type": "array", "items": {"$ref": "#/$defs/node"}}
+			},
+			"required": ["value", "children"],
+			"additionalProperties": false
+		}
+	}
+}`
+
+func TestValidateKeywords(t *testing.T) {
+	var s schema.BaseSchema
+	if err := json.Unmarshal([]byte(validateTestSchema), &s); err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want map[string]string
+	}{
+		{
+			name: "valid minimal",
+			data: `{"name":"a","unit":"C","note":null}`,
+		},
+		{
+			name: "valid with recursion",
+			data: `{"name":"a","unit":"F","note":"n","count":2,"ratio":0.5,"active":true,"tags":["x"],"root":{"value":1,"children":[{"value":2,"children":[]}]}}`,
+		},
+		{
+			name: "missing required",
+			data: `{"unit":"C"}`,
+			want: map[string]string{"$.name": "required", "$.note": "required"},
+		},
+		{
+			name: "additional property",
+			data: `{"name":"a","unit":"C","note":null,"extra":1}`,
+			want: map[string]string{"$.extra": "additionalProperties"},
+		},
+		{
+			name: "enum",
+			data: `{"name":"a","unit":"K","note":null}`,
+			want: map[string]string{"$.unit": "enum"},
+		},
+		{
+			name: "nullable wrong type",
+			data: `{"name":"a","unit":"C","note":1}`,
+			want: map[string]string{"$.note": "type"},
+		},
+		{
+			name: "null for non-nullable",
+			data: `{"name":null,"unit":"C","note":null}`,
+			want: map[string]string{"$.name": "type"},
+		},
+		{
+			name: "recursive definition",
+			data: `{"name":"a","unit":"C","note":null,"root":{"value":1,"children":[{"value":"x","children":[]},{"value":2,"extra":true}]}}`,
+			want: map[string]string{
+				"$.root.children[0].value":    "type",
+				"$.root.children[1].children": "required",
+				"$.root.children[1].extra":    "additionalProperties",
+			},
+		},
+		{
+			name: "array items",
+			data: `{"name":"a","unit":"C","note":null,"tags":["x",1,null]}`,
+			want: map[string]string{"$.tags[1]": "type", "$.tags[2]": "type"},
+		},
+		{
+			name: "type mismatches",
+			data: `{"name":"a","unit":"C","note":null,"count":1.5,"ratio":"1","active":"yes","tags":"x","root":[]}`,
+			want: map[string]string{
+				"$.count":  "type",
+				"$.ratio":  "type",
+				"$.active": "type",
+				"$.tags":   "type",
+				"$.root":   "type",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := schema.ValidateJSON(s, []byte(tt.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := map[string]string{}
+			for _, v := range violations {
+				got[v.Path] = v.Keyword
+			}
+			if len(got) != len(violations) || !maps.Equal(got, tt.want) {
+				t.Errorf("expected violations %v, got %v", tt.want, violations)
+			}
+		})
+	}
+}
@@ -1,5 +1,13 @@
 package schema
 
+// WeatherFunctionArgs は weather 関数の引数を定義します
+type WeatherFunctionArgs struct {
+	Location string `json:"location"`
+}
+
+// NewWeatherFunctionCallSchema は strict モードの weather 関数のツール定義を作成します
+// パラメーターは Lint の規則（全てのプロパティが required、additionalProperties: false）を満たすため、
+// strict を有効にしてモデルの引数がスキーマに必ず従うようにしています
 func NewWeatherFunctionCallSchema() *Tool {
 	falseValue := false
 	return &Tool{
@@ -18,7 +26,7 @@ func NewWeatherFunctionCallSchema() *Tool {
 				Required:             []string{"location"},
 				AdditionalProperties: &falseValue,
 			},
-			Strict: false,
+			Strict: true,
 		},
 	}
 }
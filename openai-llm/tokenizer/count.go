@@ -0,0 +1,184 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// チャット形式でメッセージごとに加わるトークン数です
+const (
+	// tokensPerMessage はメッセージの区切りとロールを囲むトークンです
+	tokensPerMessage = 3
+	// replyPrimingTokens はアシスタントの応答の開始を表すトークンです
+	replyPrimingTokens = 3
+	// tokensPerToolCall はツール呼び出し1件あたりの区切りのトークンです
+	tokensPerToolCall = 3
+)
+
+// ツール定義のトークン数の見積もりに使用する値です
+const (
+	propInit = 3
+	propKey  = 3
+	enumInit = -3
+	enumItem = 3
+	funcEnd  = 12
+)
+
+// 画像のトークン数の計算に使用する値です
+const (
+	imageBaseTokens = 85
+	imageTileTokens = 170
+	imageTileSize   = 512
+	imageMaxSide    = 2048
+	imageShortSide  = 768
+)
+
+// UnknownImageTokens はサイズが分からない画像（URLで指定した画像など）のトークン数です
+// 予算を超えないように、high detail で最も多くなる場合の値を使用します
+var UnknownImageTokens = ImageTokens(imageMaxSide, imageShortSide, "high")
+
+// CountMessages はメッセージをチャット形式で送信した場合のプロンプトのトークン数を返します
+// アシスタントの応答の開始を表すトークンを含みます
+func (e *Encoding) CountMessages(messages []utils.Message) int {
+	total := replyPrimingTokens
+	for _, m := range messages {
+		total += e.CountMessage(m)
+	}
+	return total
+}
+
+// CountMessage は1件のメッセージのトークン数を返します
+// テキスト、画像、アシスタントのツール呼び出しを数えます
+func (e *Encoding) CountMessage(m utils.Message) int {
+	count := tokensPerMessage + e.Count(string(m.Role)) + e.countContent(m.Content)
+	for _, call := range m.ToolCalls {
+		count += tokensPerToolCall + e.Count(call.Function.Name) + e.Count(call.Function.Arguments)
+	}
+	return count
+}
+
+// countContent は文字列または text / image_url のパーツの配列のトークン数を返します
+func (e *Encoding) countContent(content json.RawMessage) int {
+	if len(content) == 0 || string(content) == "null" {
+		return 0
+	}
+
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return e.Count(text)
+	}
+
+	var parts []utils.Content
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return e.Count(string(content))
+	}
+	count := 0
+	for _, part := range parts {
+		switch {
+		case part.Type == "image_url" && part.ImageUrl != nil:
+			count += imageURLTokens(part.ImageUrl.Url)
+		default:
+			count += e.Count(part.Text)
+		}
+	}
+	return count
+}
+
+// CountTools はツール定義のトークン数の目安を返します
+// OpenAIはツール定義を独自の形式に変換してプロンプトに含めるため、正確な値ではありません
+func (e *Encoding) CountTools(tools []schema.Tool) int {
+	if len(tools) == 0 {
+		return 0
+	}
+	funcInit := 7
+	if e.name == CL100kBase {
+		funcInit = 10
+	}
+
+	count := funcEnd
+	for _, tool := range tools {
+		f := tool.Function
+		count += funcInit + e.Count(f.Name+":"+strings.TrimSuffix(f.Description, "."))
+		count += e.countProperties(f.Parameters.Properties)
+	}
+	return count
+}
+
+// countProperties はプロパティ（入れ子のオブジェクトや配列の要素を含む）のトークン数を返します
+func (e *Encoding) countProperties(properties map[string]schema.SchemaProperty) int {
+	if len(properties) == 0 {
+		return 0
+	}
+	count := propInit
+	for _, name := range slices.Sorted(maps.Keys(properties)) {
+		count += e.countProperty(name, properties[name])
+	}
+	return count
+}
+
+func (e *Encoding) countProperty(name string, p schema.SchemaProperty) int {
+	count := propKey + e.Count(name+":"+p.Type+":"+strings.TrimSuffix(p.Description, "."))
+	if len(p.Enum) > 0 {
+		count += enumInit
+		for _, value := range p.Enum {
+			count += enumItem + e.Count(value)
+		}
+	}
+	count += e.countProperties(p.Properties)
+	if p.Items != nil {
+		count += e.countProperty("items", *p.Items)
+	}
+	return count
+}
+
+// ImageTokens は画像の入力に必要なトークン数を返します
+// detail が "low" の場合は固定値、それ以外は 512px のタイルの数から計算します
+func ImageTokens(width, height int, detail string) int {
+	if detail == "low" {
+		return imageBaseTokens
+	}
+	w, h := float64(width), float64(height)
+	// 2048px の正方形に収まるように縮小する
+	if longest := max(w, h); longest > imageMaxSide {
+		w, h = w*imageMaxSide/longest, h*imageMaxSide/longest
+	}
+	// 短辺が 768px になるように縮小する
+	if shortest := min(w, h); shortest > imageShortSide {
+		w, h = w*imageShortSide/shortest, h*imageShortSide/shortest
+	}
+	tiles := ceilDiv(int(w+0.5), imageTileSize) * ceilDiv(int(h+0.5), imageTileSize)
+	return imageBaseTokens + imageTileTokens*tiles
+}
+
+// imageURLTokens は画像のURLのトークン数を返します
+// data URL の場合は画像のサイズを読み取り、それ以外は UnknownImageTokens を返します
+func imageURLTokens(url string) int {
+	header, data, ok := strings.Cut(url, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return UnknownImageTokens
+	}
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return UnknownImageTokens
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(decoded))
+	if err != nil {
+		return UnknownImageTokens
+	}
+	return ImageTokens(config.Width, config.Height, "auto")
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,236 @@
+// Package tokenizer はOpenAIのモデルが使用するBPEエンコーディングでトークン数を数え、
+// 会話をコンテキストウィンドウに収めるための機能を提供します
+//
+// cl100k_base と o200k_base の語彙はパッケージに埋め込まれているため、ネットワークへのアクセスは不要です。
+package tokenizer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yuki5155/go-llms/openai-llm/models"
+)
+
+// エンコーディング名
+const (
+	CL100kBase = "cl100k_base"
+	O200kBase  = "o200k_base"
+)
+
+// vocab は tiktoken（MIT License）が公開している cl100k_base.tiktoken と o200k_base.tiktoken を gzip で圧縮したものです
+//
+//go:embed vocab/*.tiktoken.gz
+var vocab embed.FS
+
+// Encoding はBPEの語彙と、BPEの前にテキストを分割する規則の組み合わせです
+type Encoding struct {
+	name   string
+	ranks  map[string]int
+	tokens []string
+	split  func(text string) []string
+}
+
+// encodingSpec は埋め込まれた語彙ファイルと分割規則です
+type encodingSpec struct {
+	file  string
+	split func(text string) []string
+
+	once sync.Once
+	enc  *Encoding
+	err  error
+}
+
+var encodings = map[string]*encodingSpec{
+	CL100kBase: {file: "vocab/cl100k_base.tiktoken.gz", split: splitCL100k},
+	O200kBase:  {file: "vocab/o200k_base.tiktoken.gz", split: splitO200k},
+}
+
+// modelPrefixes はモデル名の接頭辞と対応するエンコーディングです（先に一致したものが使用されます）
+var modelPrefixes = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-5", O200kBase},
+	{"gpt-4.5", O200kBase},
+	{"gpt-4.1", O200kBase},
+	{"gpt-4o", O200kBase},
+	{"chatgpt-4o", O200kBase},
+	{"gpt-oss", O200kBase},
+	{"o1", O200kBase},
+	{"o3", O200kBase},
+	{"o4", O200kBase},
+	{"gpt-4", CL100kBase},
+	{"gpt-3.5", CL100kBase},
+	{"gpt-35", CL100kBase},
+	{"text-embedding-3", CL100kBase},
+	{"text-embedding-ada-002", CL100kBase},
+}
+
+// Get は名前を指定してエンコーディングを返します
+// 語彙は最初の呼び出しで読み込まれ、以降は同じEncodingが返されます
+func Get(name string) (*Encoding, error) {
+	spec, ok := encodings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+	spec.once.Do(func() {
+		spec.enc, spec.err = load(name, spec)
+	})
+	return spec.enc, spec.err
+}
+
+// ForModel はモデルが使用するエンコーディングを返します（例: gpt-4o → o200k_base、gpt-4 → cl100k_base）
+// models.Default() に Encoding が登録されているモデルはその値を使用します
+// ファインチューニングしたモデル（ft:gpt-4o-mini:...）は元のモデルのエンコーディングになります
+func ForModel(model string) (*Encoding, error) {
+	if m, ok := models.Default().Lookup(model); ok && m.Encoding != "" {
+		return Get(m.Encoding)
+	}
+	name := strings.TrimPrefix(model, "ft:")
+	for _, m := range modelPrefixes {
+		if strings.HasPrefix(name, m.prefix) {
+			return Get(m.encoding)
+		}
+	}
+	return nil, fmt.Errorf("no encoding known for model %q", model)
+}
+
+// load は埋め込まれた tiktoken 形式の語彙（各行が "base64のトークン ランク"）を読み込みます
+func load(name string, spec *encodingSpec) (*Encoding, error) {
+	f, err := vocab.Open(spec.file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening vocabulary for %s: %v", name, err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading vocabulary for %s: %v", name, err)
+	}
+	defer gz.Close()
+
+	enc := &Encoding{name: name, ranks: make(map[string]int), split: spec.split}
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		encoded, rankText, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		token, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding token %q in %s: %v", encoded, name, err)
+		}
+		rank, err := strconv.Atoi(rankText)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing rank %q in %s: %v", rankText, name, err)
+		}
+		enc.ranks[string(token)] = rank
+		for len(enc.tokens) <= rank {
+			enc.tokens = append(enc.tokens, "")
+		}
+		enc.tokens[rank] = string(token)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading vocabulary for %s: %v", name, err)
+	}
+	return enc, nil
+}
+
+// Name はエンコーディングの名前を返します
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Encode はテキストをトークンIDに変換します
+// <|endoftext|> のような特殊トークンも通常のテキストとして扱います
+func (e *Encoding) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range e.split(text) {
+		tokens = e.bytePairEncode(piece, tokens)
+	}
+	return tokens
+}
+
+// Decode はトークンIDをテキストに戻します（語彙にないIDは無視されます）
+func (e *Encoding) Decode(tokens []int) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		if token >= 0 && token < len(e.tokens) {
+			b.WriteString(e.tokens[token])
+		}
+	}
+	return b.String()
+}
+
+// Count はテキストのトークン数を返します
+func (e *Encoding) Count(text string) int {
+	count := 0
+	for _, piece := range e.split(text) {
+		if _, ok := e.ranks[piece]; ok {
+			count++
+			continue
+		}
+		count += len(e.bytePairEncode(piece, nil))
+	}
+	return count
+}
+
+// bytePairEncode はピースのバイト列を、ランクの低い隣接ペアから順に結合してトークンにします
+func (e *Encoding) bytePairEncode(piece string, tokens []int) []int {
+	if rank, ok := e.ranks[piece]; ok {
+		return append(tokens, rank)
+	}
+
+	// parts[i] はi番目の区切りの位置と、そこから2つ先の区切りまでを結合した場合のランクです
+	type part struct {
+		start int
+		rank  int
+	}
+	rankOf := func(s string) int {
+		if rank, ok := e.ranks[s]; ok {
+			return rank
+		}
+		return math.MaxInt
+	}
+
+	parts := make([]part, 0, len(piece)+1)
+	for i := 0; i < len(piece)-1; i++ {
+		parts = append(parts, part{start: i, rank: rankOf(piece[i : i+2])})
+	}
+	parts = append(parts, part{start: len(piece) - 1, rank: math.MaxInt}, part{start: len(piece), rank: math.MaxInt})
+
+	mergedRank := func(i int) int {
+		if i+3 < len(parts) {
+			return rankOf(piece[parts[i].start:parts[i+3].start])
+		}
+		return math.MaxInt
+	}
+
+	for {
+		minIndex, minRank := -1, math.MaxInt
+		for i, p := range parts[:len(parts)-1] {
+			if p.rank < minRank {
+				minIndex, minRank = i, p.rank
+			}
+		}
+		if minIndex < 0 {
+			break
+		}
+		if minIndex > 0 {
+			parts[minIndex-1].rank = mergedRank(minIndex - 1)
+		}
+		parts[minIndex].rank = mergedRank(minIndex)
+		parts = append(parts[:minIndex+1], parts[minIndex+2:]...)
+	}
+
+	for i := 0; i < len(parts)-1; i++ {
+		tokens = append(tokens, e.ranks[piece[parts[i].start:parts[i+1].start]])
+	}
+	return tokens
+}
@@ -0,0 +1,206 @@
+package tokenizer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tiktoken はBPEの前に正規表現でテキストをピースに分割しますが、その正規表現は
+// Goの regexp が対応していない先読み（\s+(?!\S)）を含むため、同じ規則を手書きで実装しています。
+// 各選択肢は正規表現と同じく先に書かれたものから順に試し、最初に一致したものを採用します。
+//
+// cl100k_base:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// o200k_base:
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?
+//	|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?
+//	|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+
+// splitCL100k は cl100k_base の規則でテキストを分割します
+func splitCL100k(text string) []string {
+	return split(text, func(rs []rune, i int) int {
+		if n := contraction(rs, i); n > 0 {
+			return i + n
+		}
+		if end := letters(rs, i); end > i {
+			return end
+		}
+		if end := digits(rs, i); end > i {
+			return end
+		}
+		if end := punctuation(rs, i, "\r\n"); end > i {
+			return end
+		}
+		return whitespace(rs, i)
+	})
+}
+
+// splitO200k は o200k_base の規則でテキストを分割します
+func splitO200k(text string) []string {
+	return split(text, func(rs []rune, i int) int {
+		for _, word := range []func(rs []rune, start int) int{casedWord, capitalizedWord} {
+			if end := withPrefix(rs, i, word); end > i {
+				return end + contraction(rs, end)
+			}
+		}
+		if end := digits(rs, i); end > i {
+			return end
+		}
+		if end := punctuation(rs, i, "\r\n/"); end > i {
+			return end
+		}
+		return whitespace(rs, i)
+	})
+}
+
+// split は位置 i から始まるピースの終端を返す関数でテキストを分割します
+func split(text string, piece func(rs []rune, i int) int) []string {
+	rs := make([]rune, 0, len(text))
+	offsets := make([]int, 0, len(text)+1)
+	for offset, r := range text {
+		rs = append(rs, r)
+		offsets = append(offsets, offset)
+	}
+	offsets = append(offsets, len(text))
+
+	var pieces []string
+	for i := 0; i < len(rs); {
+		end := piece(rs, i)
+		if end <= i {
+			end = i + 1
+		}
+		pieces = append(pieces, text[offsets[i]:offsets[end]])
+		i = end
+	}
+	return pieces
+}
+
+// contraction は (?i:'s|'t|'re|'ve|'m|'ll|'d) に一致する長さを返します（一致しない場合は0）
+func contraction(rs []rune, i int) int {
+	if i >= len(rs) || rs[i] != '\'' {
+		return 0
+	}
+	for _, suffix := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+		n := utf8.RuneCountInString(suffix)
+		if i+1+n <= len(rs) && strings.EqualFold(string(rs[i+1:i+1+n]), suffix) {
+			return 1 + n
+		}
+	}
+	return 0
+}
+
+// letters は [^\r\n\p{L}\p{N}]?\p{L}+ に一致する終端を返します
+func letters(rs []rune, i int) int {
+	start := i
+	if !unicode.IsLetter(rs[i]) {
+		if !isPrefix(rs[i]) || i+1 >= len(rs) || !unicode.IsLetter(rs[i+1]) {
+			return i
+		}
+		start = i + 1
+	}
+	return span(rs, start, unicode.IsLetter)
+}
+
+// withPrefix は [^\r\n\p{L}\p{N}]? に続けて word に一致する終端を返します
+// 正規表現と同じく、先頭の1文字を含める場合を先に試します
+func withPrefix(rs []rune, i int, word func(rs []rune, start int) int) int {
+	if isPrefix(rs[i]) && i+1 < len(rs) {
+		if end := word(rs, i+1); end > i+1 {
+			return end
+		}
+	}
+	return word(rs, i)
+}
+
+// casedWord は [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+ に一致する終端を返します
+func casedWord(rs []rune, start int) int {
+	upper := span(rs, start, isUpperish)
+	// 大文字側が長すぎて小文字側が一致しない場合は、正規表現と同じく大文字側を1文字ずつ戻します
+	for k := upper; k >= start; k-- {
+		if k < len(rs) && isLowerish(rs[k]) {
+			return span(rs, k, isLowerish)
+		}
+	}
+	return start
+}
+
+// capitalizedWord は [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]* に一致する終端を返します
+func capitalizedWord(rs []rune, start int) int {
+	upper := span(rs, start, isUpperish)
+	if upper == start {
+		return start
+	}
+	return span(rs, upper, isLowerish)
+}
+
+// digits は \p{N}{1,3} に一致する終端を返します
+func digits(rs []rune, i int) int {
+	end := i
+	for end < len(rs) && end-i < 3 && unicode.IsNumber(rs[end]) {
+		end++
+	}
+	return end
+}
+
+// punctuation は ` ?[^\s\p{L}\p{N}]+[trailing]*` に一致する終端を返します
+func punctuation(rs []rune, i int, trailing string) int {
+	start := i
+	if rs[i] == ' ' && i+1 < len(rs) && isPunctuation(rs[i+1]) {
+		start = i + 1
+	}
+	if !isPunctuation(rs[start]) {
+		return i
+	}
+	end := span(rs, start, isPunctuation)
+	return span(rs, end, func(r rune) bool { return strings.ContainsRune(trailing, r) })
+}
+
+// whitespace は \s*[\r\n]+|\s+(?!\S)|\s+ に一致する終端を返します
+func whitespace(rs []rune, i int) int {
+	end := span(rs, i, unicode.IsSpace)
+	if end == i {
+		return i
+	}
+	// \s*[\r\n]+ は空白の並びの中の最後の改行までに一致します
+	for k := end - 1; k >= i; k-- {
+		if rs[k] == '\r' || rs[k] == '\n' {
+			return k + 1
+		}
+	}
+	// \s+(?!\S) は空白以外の文字の直前の空白を残します
+	if end < len(rs) && end-1 > i {
+		return end - 1
+	}
+	return end
+}
+
+// span は start から f を満たす文字が続く終端を返します
+func span(rs []rune, start int, f func(rune) bool) int {
+	end := start
+	for end < len(rs) && f(rs[end]) {
+		end++
+	}
+	return end
+}
+
+// isPrefix は [^\r\n\p{L}\p{N}] に一致するかを判定します
+func isPrefix(r rune) bool {
+	return r != '\r' && r != '\n' && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// isPunctuation は [^\s\p{L}\p{N}] に一致するかを判定します
+func isPunctuation(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+func isUpperish(r rune) bool {
+	return unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+func isLowerish(r rune) bool {
+	return unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
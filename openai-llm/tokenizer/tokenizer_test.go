@@ -0,0 +1,122 @@
+package tokenizer_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"reflect"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/tokenizer"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		encoding string
+		text     string
+		want     []int
+	}{
+		{tokenizer.CL100kBase, "hello world", []int{15339, 1917}},
+		{tokenizer.CL100kBase, "tiktoken is great!", []int{83, 1609, 5963, 374, 2294, 0}},
+		{tokenizer.CL100kBase, "I'm don't WE'LL", []int{40, 2846, 1541, 956, 20255, 6, 4178}},
+		{tokenizer.CL100kBase, "日本語のテキスト", []int{9080, 22656, 45918, 252, 16144, 57933, 62903, 71634}},
+		{tokenizer.CL100kBase, "  indented\n\n\tcode() // comment\n", []int{220, 1280, 16243, 271, 44443, 368, 443, 4068, 198}},
+		{tokenizer.O200kBase, "hello world", []int{24912, 2375}},
+		{tokenizer.O200kBase, "tiktoken is great!", []int{83, 8251, 2488, 382, 2212, 0}},
+		{tokenizer.O200kBase, "I'm don't WE'LL", []int{15390, 4128, 26919, 6, 7454}},
+		{tokenizer.O200kBase, "日本語のテキスト", []int{9048, 40909, 3385, 16056, 18368, 38236}},
+		{tokenizer.O200kBase, "  indented\n\n\tcode() // comment\n", []int{220, 1383, 23537, 279, 86873, 416, 602, 5375, 198}},
+	}
+	for _, tt := range tests {
+		enc, err := tokenizer.Get(tt.encoding)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := enc.Encode(tt.text)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %q: want %v, got %v", tt.encoding, tt.text, tt.want, got)
+		}
+		if n := enc.Count(tt.text); n != len(tt.want) {
+			t.Errorf("%s %q: expected count %d, got %d", tt.encoding, tt.text, len(tt.want), n)
+		}
+		if decoded := enc.Decode(got); decoded != tt.text {
+			t.Errorf("%s: expected round trip, got %q", tt.encoding, decoded)
+		}
+	}
+}
+
+func TestForModel(t *testing.T) {
+	for model, want := range map[string]string{
+		"gpt-4o-2024-08-06":    tokenizer.O200kBase,
+		"gpt-4o-mini":          tokenizer.O200kBase,
+		"o3-mini":              tokenizer.O200kBase,
+		"ft:gpt-4.1-nano:acme": tokenizer.O200kBase,
+		"gpt-4-turbo":          tokenizer.CL100kBase,
+		"gpt-3.5-turbo":        tokenizer.CL100kBase,
+	} {
+		enc, err := tokenizer.ForModel(model)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", model, err)
+		}
+		if enc.Name() != want {
+			t.Errorf("%s: expected %s, got %s", model, want, enc.Name())
+		}
+	}
+	if _, err := tokenizer.ForModel("llama3"); err == nil {
+		t.Error("expected error for unknown model")
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	enc, err := tokenizer.Get(tokenizer.CL100kBase)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// OpenAIのクックブックの例と同じく、各メッセージに3トークン、応答の開始に3トークンが加わる
+	messages := []utils.Message{
+		utils.NewMessage(utils.RoleSystem, "You are a helpful assistant."),
+		utils.NewMessage(utils.RoleUser, "hello world"),
+	}
+	want := 3 + (3 + 1 + 6) + (3 + 1 + 2)
+	if got := enc.CountMessages(messages); got != want {
+		t.Errorf("expected %d tokens, got %d", want, got)
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1024, 1024)))
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	withImage := utils.NewMessageWithImage(dataURL, "hello world")
+	if got, want := enc.CountMessage(withImage), 3+1+765+2; got != want {
+		t.Errorf("expected %d tokens for image message, got %d", want, got)
+	}
+	remote := utils.NewMessageWithImage("https://example.com/photo.jpg", "hello world")
+	if got, want := enc.CountMessage(remote), 3+1+tokenizer.UnknownImageTokens+2; got != want {
+		t.Errorf("expected %d tokens for remote image, got %d", want, got)
+	}
+
+	if n := enc.CountTools([]schema.Tool{*schema.NewWeatherFunctionCallSchema()}); n <= 12 {
+		t.Errorf("expected tool definitions to be counted, got %d", n)
+	}
+}
+
+func TestImageTokens(t *testing.T) {
+	tests := []struct {
+		width, height int
+		detail        string
+		want          int
+	}{
+		{1024, 1024, "high", 765},
+		{2048, 4096, "high", 1105},
+		{4096, 8192, "low", 85},
+		{512, 512, "auto", 255},
+	}
+	for _, tt := range tests {
+		if got := tokenizer.ImageTokens(tt.width, tt.height, tt.detail); got != tt.want {
+			t.Errorf("%dx%d %s: expected %d, got %d", tt.width, tt.height, tt.detail, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package tokenizer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// ErrContextOverflow はメッセージを削っても予算に収まらない場合のエラーです
+var ErrContextOverflow = errors.New("messages do not fit in the token budget")
+
+// Strategy はメッセージをトークン数の予算に収める方法です
+//
+// 予算にはプロンプトに使えるトークン数（コンテキストウィンドウから応答とツール定義の分を引いたもの）を指定します。
+// アシスタントのツール呼び出しと、それに対するツールの結果は常にまとめて残すか削除します。
+// 最後のメッセージは削除されません。
+type Strategy interface {
+	Fit(ctx context.Context, enc *Encoding, messages []utils.Message, budget int) ([]utils.Message, error)
+}
+
+// DropOldest はシステムプロンプトを含め、古いメッセージから順に削除します
+type DropOldest struct{}
+
+// Fit は予算に収まるまで古いメッセージを削除します
+func (DropOldest) Fit(ctx context.Context, enc *Encoding, messages []utils.Message, budget int) ([]utils.Message, error) {
+	return dropOldest(enc, messages, budget, func(utils.Message) bool { return false })
+}
+
+// KeepSystem はシステムプロンプトを残し、それ以外の古いメッセージから順に削除します
+type KeepSystem struct{}
+
+// Fit は予算に収まるまでシステムプロンプト以外の古いメッセージを削除します
+func (KeepSystem) Fit(ctx context.Context, enc *Encoding, messages []utils.Message, budget int) ([]utils.Message, error) {
+	return dropOldest(enc, messages, budget, isSystem)
+}
+
+// Summarizer はメッセージを要約したテキストを返します
+type Summarizer func(ctx context.Context, messages []utils.Message) (string, error)
+
+// DefaultSummaryTokens は SummarizeMiddle.SummaryTokens が0の場合に要約のために確保するトークン数です
+const DefaultSummaryTokens = 512
+
+// SummarizeMiddle は先頭のシステムプロンプトと直近のメッセージを残し、その間のメッセージを要約に置き換えます
+// 要約は SummaryTokens に収まるように切り詰められます
+// 要約を加えても予算に収まらない場合は、KeepSystem と同じく古いメッセージから削除します
+type SummarizeMiddle struct {
+	Summarize Summarizer
+	// SummaryTokens は要約のために確保するトークン数です
+	SummaryTokens int
+}
+
+// Fit は中間のメッセージを要約して予算に収めます
+func (s SummarizeMiddle) Fit(ctx context.Context, enc *Encoding, messages []utils.Message, budget int) ([]utils.Message, error) {
+	if enc.CountMessages(messages) <= budget {
+		return messages, nil
+	}
+	if s.Summarize == nil {
+		return nil, fmt.Errorf("summarize-middle strategy requires a summarizer")
+	}
+	reserve := s.SummaryTokens
+	if reserve <= 0 {
+		reserve = DefaultSummaryTokens
+	}
+
+	groups := group(messages)
+	head := 0
+	for head < len(groups) && isSystem(groups[head][0]) {
+		head++
+	}
+
+	// 先頭と要約の分を除いた予算に収まるだけ、直近のメッセージを残す
+	used := replyPrimingTokens + reserve + tokensPerMessage
+	for _, g := range groups[:head] {
+		used += countGroup(enc, g)
+	}
+	if head == len(groups) {
+		return KeepSystem{}.Fit(ctx, enc, messages, budget)
+	}
+	// 最後のまとまりは必ず残す
+	tail := len(groups) - 1
+	used += countGroup(enc, groups[tail])
+	for tail > head {
+		cost := countGroup(enc, groups[tail-1])
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		tail--
+	}
+	if tail == head {
+		return KeepSystem{}.Fit(ctx, enc, messages, budget)
+	}
+
+	summary, err := s.Summarize(ctx, flatten(groups[head:tail]))
+	if err != nil {
+		return nil, fmt.Errorf("error summarizing messages: %w", err)
+	}
+	// 要約はシステムメッセージとして固定されるため、確保した分を超えると KeepSystem が直近のメッセージを削除してしまう
+	content := truncateText(enc, "Summary of the earlier conversation:\n"+summary, reserve-enc.Count(string(utils.RoleSystem)))
+	fitted := flatten(groups[:head])
+	fitted = append(fitted, utils.NewMessage(utils.RoleSystem, content))
+	fitted = append(fitted, flatten(groups[tail:])...)
+	return KeepSystem{}.Fit(ctx, enc, fitted, budget)
+}
+
+// truncateText は text を limit トークン以下に切り詰めます
+func truncateText(enc *Encoding, text string, limit int) string {
+	tokens := enc.Encode(text)
+	for n := limit; len(tokens) > limit; n-- {
+		if n <= 0 {
+			return ""
+		}
+		// トークンの途中で切れたマルチバイト文字は取り除く
+		text = strings.ToValidUTF8(enc.Decode(tokens[:n]), "")
+		tokens = enc.Encode(text)
+	}
+	return text
+}
+
+// ClientSummarizer はモデルに会話の要約を依頼するSummarizerを返します
+func ClientSummarizer(client *utils.Client) Summarizer {
+	return func(ctx context.Context, messages []utils.Message) (string, error) {
+		completion, err := client.SendRequestWithFunctionCallContext(ctx, utils.RequestOptions{
+			Messages: []utils.Message{
+				utils.NewMessage(utils.RoleSystem, "Summarize the following conversation concisely. Keep facts, decisions, open questions and tool results that later turns may rely on."),
+				utils.NewMessage(utils.RoleUser, Transcript(messages)),
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(completion.Choices) == 0 {
+			return "", fmt.Errorf("no choices available")
+		}
+		return completion.Choices[0].Message.Text(), nil
+	}
+}
+
+// Transcript はメッセージを "role: 内容" の形式のテキストにします（画像は [image] と表記されます）
+func Transcript(messages []utils.Message) string {
+	var b strings.Builder
+	for _, m := range messages {
+		if text := messageText(m); text != "" || len(m.ToolCalls) == 0 {
+			fmt.Fprintf(&b, "%s: %s\n", m.Role, text)
+		}
+		for _, call := range m.ToolCalls {
+			fmt.Fprintf(&b, "%s: called %s(%s)\n", m.Role, call.Function.Name, call.Function.Arguments)
+		}
+	}
+	return b.String()
+}
+
+// dropOldest は予算に収まるまで、pinned でない古いメッセージのまとまりから削除します
+func dropOldest(enc *Encoding, messages []utils.Message, budget int, pinned func(utils.Message) bool) ([]utils.Message, error) {
+	groups := group(messages)
+	total := replyPrimingTokens
+	for _, g := range groups {
+		total += countGroup(enc, g)
+	}
+
+	for i := 0; total > budget && i < len(groups)-1; {
+		if pinned(groups[i][0]) {
+			i++
+			continue
+		}
+		total -= countGroup(enc, groups[i])
+		groups = slices.Delete(groups, i, i+1)
+	}
+	if total > budget {
+		return nil, fmt.Errorf("%w: %d tokens remain, budget is %d", ErrContextOverflow, total, budget)
+	}
+	return flatten(groups), nil
+}
+
+// group はアシスタントのツール呼び出しとそれに続くツールの結果を1つのまとまりにします
+func group(messages []utils.Message) [][]utils.Message {
+	var groups [][]utils.Message
+	for _, m := range messages {
+		if m.Role == utils.RoleTool && len(groups) > 0 {
+			last := groups[len(groups)-1]
+			if last[0].Role == utils.RoleAssistant && len(last[0].ToolCalls) > 0 {
+				groups[len(groups)-1] = append(last, m)
+				continue
+			}
+		}
+		groups = append(groups, []utils.Message{m})
+	}
+	return groups
+}
+
+func flatten(groups [][]utils.Message) []utils.Message {
+	var messages []utils.Message
+	for _, g := range groups {
+		messages = append(messages, g...)
+	}
+	return messages
+}
+
+func countGroup(enc *Encoding, g []utils.Message) int {
+	count := 0
+	for _, m := range g {
+		count += enc.CountMessage(m)
+	}
+	return count
+}
+
+func isSystem(m utils.Message) bool {
+	return m.Role == utils.RoleSystem
+}
+
+// messageText はメッセージのテキストを返します
+func messageText(m utils.Message) string {
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return text
+	}
+	var parts []utils.Content
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return string(m.Content)
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "image_url" {
+			texts = append(texts, "[image]")
+		} else {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
@@ -0,0 +1,115 @@
+package tokenizer_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/tokenizer"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+func longConversation() []utils.Message {
+	filler := strings.Repeat("word ", 50)
+	toolCall := utils.Message{
+		Role: utils.RoleAssistant,
+		ToolCalls: []utils.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: utils.Function{Name: "weather", Arguments: `{"location":"Tokyo"}`},
+		}},
+	}
+	return []utils.Message{
+		utils.NewMessage(utils.RoleSystem, "You are a helpful assistant."),
+		utils.NewMessage(utils.RoleUser, "first "+filler),
+		toolCall,
+		utils.NewToolMessage("call_1", `{"temperature":20} `+filler),
+		utils.NewMessage(utils.RoleAssistant, "second "+filler),
+		utils.NewMessage(utils.RoleUser, "third "+filler),
+		utils.NewMessage(utils.RoleAssistant, "fourth "+filler),
+		utils.NewMessage(utils.RoleUser, "What about tomorrow?"),
+	}
+}
+
+func roles(messages []utils.Message) string {
+	names := make([]string, len(messages))
+	for i, m := range messages {
+		names[i] = string(m.Role)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestTruncationStrategies(t *testing.T) {
+	enc, err := tokenizer.Get(tokenizer.O200kBase)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	messages := longConversation()
+	budget := enc.CountMessages(messages) - 100
+
+	kept, err := tokenizer.KeepSystem{}.Fit(ctx, enc, messages, budget)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// ツール呼び出しと結果はまとめて削除される
+	if got := roles(kept); got != "system,assistant,user,assistant,user" {
+		t.Errorf("unexpected messages after KeepSystem: %s", got)
+	}
+	if n := enc.CountMessages(kept); n > budget {
+		t.Errorf("expected at most %d tokens, got %d", budget, n)
+	}
+
+	dropped, err := tokenizer.DropOldest{}.Fit(ctx, enc, messages, budget)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := roles(dropped); got != "assistant,user,assistant,user" {
+		t.Errorf("unexpected messages after DropOldest: %s", got)
+	}
+
+	if _, err := (tokenizer.KeepSystem{}).Fit(ctx, enc, messages, 20); !errors.Is(err, tokenizer.ErrContextOverflow) {
+		t.Errorf("expected ErrContextOverflow, got %v", err)
+	}
+
+	var summarized []utils.Message
+	strategy := tokenizer.SummarizeMiddle{
+		SummaryTokens: 50,
+		Summarize: func(ctx context.Context, messages []utils.Message) (string, error) {
+			summarized = messages
+			return "The user asked about the weather in Tokyo.", nil
+		},
+	}
+	fitted, err := strategy.Fit(ctx, enc, messages, 200)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := roles(fitted); got != "system,system,user,assistant,user" {
+		t.Errorf("unexpected messages after SummarizeMiddle: %s", got)
+	}
+	if len(summarized) != 4 || !strings.Contains(tokenizer.Transcript(summarized), "called weather") {
+		t.Errorf("expected the middle messages to be summarized, got %s", tokenizer.Transcript(summarized))
+	}
+	if n := enc.CountMessages(fitted); n > 200 {
+		t.Errorf("expected at most 200 tokens, got %d", n)
+	}
+
+	// 確保した分より長い要約は、直近のメッセージではなく要約が切り詰められる
+	strategy.Summarize = func(ctx context.Context, messages []utils.Message) (string, error) {
+		return strings.Repeat("The user asked about the weather in Tokyo. ", 100), nil
+	}
+	fitted, err = strategy.Fit(ctx, enc, messages, 200)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := roles(fitted); got != "system,system,user,assistant,user" {
+		t.Errorf("expected recent turns to be kept with a long summary, got %s", got)
+	}
+	if n := enc.CountMessage(fitted[1]); n > strategy.SummaryTokens+3 {
+		t.Errorf("expected the summary to fit in SummaryTokens, got %d tokens", n)
+	}
+	if n := enc.CountMessages(fitted); n > 200 {
+		t.Errorf("expected at most 200 tokens, got %d", n)
+	}
+}
@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError は200以外のステータスコードが返されたことを表します
+// errors.As で取り出し、ステータスコードやエラーコードで原因を判別できます
+type APIError struct {
+	// StatusCode はHTTPステータスコードです
+	StatusCode int
+	// Type は error.type の値です（Geminiの場合は error.status）
+	Type string
+	// Code は error.code の値です（数値の場合は文字列に変換されます）
+	Code string
+	// Param はエラーの原因となったパラメーター名です
+	Param string
+	// Message はAPIが返したエラーメッセージです
+	Message string
+	// RequestID はサポートへの問い合わせに使用するリクエストIDです
+	RequestID string
+	// RateLimit はレスポンスヘッダーから取得したレート制限の情報です
+	RateLimit RateLimit
+	// Header はレスポンスヘッダーです
+	Header http.Header
+	// Body はレスポンスボディです
+	Body []byte
+}
+
+// RateLimit は x-ratelimit-* ヘッダーの値です（ヘッダーがない項目はゼロ値になります）
+type RateLimit struct {
+	LimitRequests     int
+	LimitTokens       int
+	RemainingRequests int
+	RemainingTokens   int
+	ResetRequests     time.Duration
+	ResetTokens       time.Duration
+}
+
+// NewAPIError はレスポンスとボディからAPIErrorを作成します
+// OpenAI、Anthropic、Geminiの {"error": {...}} 形式のボディを解析します
+func NewAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		RequestID:  requestID(resp.Header),
+		RateLimit:  parseRateLimit(resp.Header),
+	}
+
+	var payload struct {
+		Error struct {
+			Message string          `json:"message"`
+			Type    string          `json:"type"`
+			Status  string          `json:"status"`
+			Param   string          `json:"param"`
+			Code    json.RawMessage `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		apiErr.Message = payload.Error.Message
+		apiErr.Type = payload.Error.Type
+		if apiErr.Type == "" {
+			apiErr.Type = payload.Error.Status
+		}
+		apiErr.Param = payload.Error.Param
+		apiErr.Code = rawCode(payload.Error.Code)
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "API returned non-200 status code: %d", e.StatusCode)
+	if e.Type != "" {
+		fmt.Fprintf(&b, ", type: %s", e.Type)
+	}
+	if e.Code != "" {
+		fmt.Fprintf(&b, ", code: %s", e.Code)
+	}
+	if e.Param != "" {
+		fmt.Fprintf(&b, ", param: %s", e.Param)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ", message: %s", e.Message)
+	} else {
+		fmt.Fprintf(&b, ", body: %s", string(e.Body))
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request id: %s)", e.RequestID)
+	}
+	return b.String()
+}
+
+// RetryAfter はサーバーが指定した再試行までの待機時間を返します
+// Retry-After を優先し、なければ429の場合に限り、残りが0になった制限の x-ratelimit-reset-* を返します
+func (e *APIError) RetryAfter() (time.Duration, bool) {
+	// リセットヘッダーは全てのレスポンスに付くため、5xx などではレート制限の待機に使わない
+	if e.StatusCode != http.StatusTooManyRequests && e.Header.Get("Retry-After") == "" {
+		return 0, false
+	}
+	return retryAfter(e.Header, time.Now())
+}
+
+// IsRateLimited はエラーがレート制限によるものかを判定します
+// 利用枠の不足（insufficient_quota）は待機しても解消しないため含みません
+func IsRateLimited(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code == "insufficient_quota" {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests ||
+		apiErr.Type == "rate_limit_error" ||
+		apiErr.Code == "rate_limit_exceeded"
+}
+
+// IsContextLengthExceeded はエラーが入力トークン数の上限超過によるものかを判定します
+func IsContextLengthExceeded(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.Code == "context_length_exceeded" {
+		return true
+	}
+	if apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusRequestEntityTooLarge {
+		return false
+	}
+	message := strings.ToLower(apiErr.Message)
+	for _, hint := range []string{"maximum context length", "prompt is too long", "exceeds the maximum number of tokens", "context window"} {
+		if strings.Contains(message, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAuthError はエラーがAPIキーの誤りや権限不足によるものかを判定します
+func IsAuthError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	}
+	switch apiErr.Type {
+	case "authentication_error", "permission_error", "UNAUTHENTICATED", "PERMISSION_DENIED":
+		return true
+	}
+	return apiErr.Code == "invalid_api_key"
+}
+
+// requestID はレスポンスヘッダーからリクエストIDを取得します
+func requestID(header http.Header) string {
+	for _, key := range []string{"x-request-id", "request-id", "apim-request-id"} {
+		if value := header.Get(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseRateLimit は x-ratelimit-* ヘッダーを解析します
+func parseRateLimit(header http.Header) RateLimit {
+	atoi := func(key string) int {
+		n, _ := strconv.Atoi(header.Get(key))
+		return n
+	}
+	duration := func(key string) time.Duration {
+		d, _ := time.ParseDuration(header.Get(key))
+		return d
+	}
+	return RateLimit{
+		LimitRequests:     atoi("x-ratelimit-limit-requests"),
+		LimitTokens:       atoi("x-ratelimit-limit-tokens"),
+		RemainingRequests: atoi("x-ratelimit-remaining-requests"),
+		RemainingTokens:   atoi("x-ratelimit-remaining-tokens"),
+		ResetRequests:     duration("x-ratelimit-reset-requests"),
+		ResetTokens:       duration("x-ratelimit-reset-tokens"),
+	}
+}
+
+// rawCode は文字列または数値の error.code を文字列に変換します
+func rawCode(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var code string
+	if err := json.Unmarshal(raw, &code); err == nil {
+		return code
+	}
+	return string(raw)
+}
@@ -0,0 +1,169 @@
+package utils_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+func TestAPIError(t *testing.T) {
+	header := http.Header{
+		"X-Request-Id":                   []string{"req_123"},
+		"X-Ratelimit-Remaining-Requests": []string{"0"},
+		"X-Ratelimit-Limit-Requests":     []string{"500"},
+		"X-Ratelimit-Reset-Requests":     []string{"1.5s"},
+	}
+	server, _ := newFlakyServer(t, 1, http.StatusTooManyRequests, header,
+		`{"error":{"message":"Rate limit reached","type":"requests","param":null,"code":"rate_limit_exceeded"}}`)
+	client := newTestClient(server)
+
+	_, err := client.SendRequestWithFunctionCall(utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	})
+	wrapped := fmt.Errorf("calling model: %w", err)
+
+	var apiErr *utils.APIError
+	if !errors.As(wrapped, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Code != "rate_limit_exceeded" || apiErr.Type != "requests" {
+		t.Errorf("unexpected error fields: %+v", apiErr)
+	}
+	if apiErr.RequestID != "req_123" || !strings.Contains(apiErr.Error(), "req_123") {
+		t.Errorf("expected request id in error, got %q", apiErr.Error())
+	}
+	if apiErr.RateLimit.LimitRequests != 500 || apiErr.RateLimit.RemainingRequests != 0 || apiErr.RateLimit.ResetRequests != 1500*time.Millisecond {
+		t.Errorf("unexpected rate limit: %+v", apiErr.RateLimit)
+	}
+	if !utils.IsRateLimited(wrapped) || utils.IsAuthError(wrapped) || utils.IsContextLengthExceeded(wrapped) {
+		t.Errorf("unexpected classification for %v", err)
+	}
+}
+
+func TestAPIErrorRetryAfter(t *testing.T) {
+	exhausted := http.Header{
+		"X-Ratelimit-Remaining-Requests": []string{"0"},
+		"X-Ratelimit-Reset-Requests":     []string{"2s"},
+		"X-Ratelimit-Remaining-Tokens":   []string{"12000"},
+		"X-Ratelimit-Reset-Tokens":       []string{"1m"},
+	}
+	tests := []struct {
+		name       string
+		statusCode int
+		header     http.Header
+		wait       time.Duration
+		ok         bool
+	}{
+		{"retry-after", http.StatusServiceUnavailable, http.Header{"Retry-After": []string{"3"}}, 3 * time.Second, true},
+		{"exhausted limit only", http.StatusTooManyRequests, exhausted, 2 * time.Second, true},
+		{"reset headers ignored on 5xx", http.StatusServiceUnavailable, exhausted, 0, false},
+		{"no exhausted limit", http.StatusTooManyRequests, http.Header{"X-Ratelimit-Reset-Tokens": []string{"1m"}}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := &utils.APIError{StatusCode: tt.statusCode, Header: tt.header}
+			wait, ok := apiErr.RetryAfter()
+			if wait != tt.wait || ok != tt.ok {
+				t.Errorf("RetryAfter() = %v, %v, want %v, %v", wait, ok, tt.wait, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAPIErrorClassification(t *testing.T) {
+	tests := []struct {
+		name          string
+		statusCode    int
+		body          string
+		rateLimited   bool
+		contextLength bool
+		auth          bool
+	}{
+		{
+			name:       "invalid api key",
+			statusCode: http.StatusUnauthorized,
+			body:       `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`,
+			auth:       true,
+		},
+		{
+			name:          "context length",
+			statusCode:    http.StatusBadRequest,
+			body:          `{"error":{"message":"This model's maximum context length is 128000 tokens.","type":"invalid_request_error","param":"messages","code":"context_length_exceeded"}}`,
+			contextLength: true,
+		},
+		{
+			name:       "insufficient quota",
+			statusCode: http.StatusTooManyRequests,
+			body:       `{"error":{"message":"You exceeded your current quota","type":"insufficient_quota","code":"insufficient_quota"}}`,
+		},
+		{
+			name:          "anthropic prompt too long",
+			statusCode:    http.StatusBadRequest,
+			body:          `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`,
+			contextLength: true,
+		},
+		{
+			name:        "gemini resource exhausted",
+			statusCode:  http.StatusTooManyRequests,
+			body:        `{"error":{"code":429,"message":"Resource has been exhausted","status":"RESOURCE_EXHAUSTED"}}`,
+			rateLimited: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newFlakyServer(t, 1, tt.statusCode, nil, tt.body)
+			client := newTestClient(server)
+			_, err := client.SendRequestWithFunctionCall(utils.RequestOptions{
+				Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+			})
+			if got := utils.IsRateLimited(err); got != tt.rateLimited {
+				t.Errorf("IsRateLimited = %v, want %v (%v)", got, tt.rateLimited, err)
+			}
+			if got := utils.IsContextLengthExceeded(err); got != tt.contextLength {
+				t.Errorf("IsContextLengthExceeded = %v, want %v (%v)", got, tt.contextLength, err)
+			}
+			if got := utils.IsAuthError(err); got != tt.auth {
+				t.Errorf("IsAuthError = %v, want %v (%v)", got, tt.auth, err)
+			}
+		})
+	}
+}
+
+func TestResponseErrorSentinels(t *testing.T) {
+	refusal := "I can't help with that."
+	var refused utils.ResponseChoice
+	refused.FinishReason = "stop"
+	refused.Message.Refusal = &refusal
+
+	tests := []struct {
+		name   string
+		resp   *utils.APIResponse
+		target error
+	}{
+		{"nil response", nil, utils.ErrNullResponse},
+		{"no choices", &utils.APIResponse{}, utils.ErrNoChoices},
+		{"refusal", &utils.APIResponse{Choices: []utils.ResponseChoice{refused}}, utils.ErrModelRefusal},
+		{"token limit", &utils.APIResponse{Choices: []utils.ResponseChoice{{FinishReason: "length"}}}, utils.ErrTokenLimit},
+		{"content filter", &utils.APIResponse{Choices: []utils.ResponseChoice{{FinishReason: "content_filter"}}}, utils.ErrContentFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := utils.HandleResponse[schema.WeatherResponse](tt.resp)
+			if !errors.Is(err, tt.target) {
+				t.Errorf("expected errors.Is(%v, %v)", err, tt.target)
+			}
+			var respErr *utils.ResponseError
+			if !errors.As(err, &respErr) {
+				t.Errorf("expected *ResponseError, got %T", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+)
+
+// ArgumentError はツール呼び出しの引数がスキーマに従っていないことを表します
+type ArgumentError struct {
+	// Function は呼び出された関数の名前です
+	Function string
+	// Missing は不足している必須フィールドのパスです
+	Missing []string
+	// Mistyped は型が一致しないフィールドのパスです
+	Mistyped []string
+	// Violations は検出された全ての違反です
+	Violations []schema.Violation
+	// Err は引数がJSONとして解釈できなかった場合のエラーです
+	Err error
+}
+
+func (e *ArgumentError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid arguments for %s: %v", e.Function, e.Err)
+	}
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.String())
+	}
+	return fmt.Sprintf("invalid arguments for %s: %s", e.Function, strings.Join(messages, "; "))
+}
+
+func (e *ArgumentError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeOption は DecodeArguments の動作を変更するオプションです
+type DecodeOption func(*decodeOptions)
+
+type decodeOptions struct {
+	schema  *schema.BaseSchema
+	lenient bool
+}
+
+// WithTool はツール定義のパラメーターで引数を検証します
+func WithTool(tool schema.Tool) DecodeOption {
+	return WithArgumentSchema(tool.Function.Parameters)
+}
+
+// WithArgumentSchema は指定したスキーマで引数を検証します
+func WithArgumentSchema(s schema.BaseSchema) DecodeOption {
+	return func(o *decodeOptions) {
+		o.schema = &s
+	}
+}
+
+// WithLenient はMarkdownのコードブロック、シングルクォート、末尾のカンマといった
+// モデルによくある誤りを修復してから引数をデコードします
+func WithLenient() DecodeOption {
+	return func(o *decodeOptions) {
+		o.lenient = true
+	}
+}
+
+// DecodeArguments はツール呼び出しの引数をスキーマで検証し、型 T にデコードします
+// スキーマを指定しない場合は検証せずにデコードします
+// 検証に失敗した場合は不足または型の一致しないフィールドを含む *ArgumentError を返します
+func DecodeArguments[T any](call *ToolCall, opts ...DecodeOption) (*T, error) {
+	if call == nil {
+		return nil, fmt.Errorf("tool call is nil")
+	}
+	var options decodeOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	arguments := call.Function.Arguments
+	if options.lenient {
+		arguments = repairJSON(arguments)
+	}
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(arguments), &value); err != nil {
+		return nil, &ArgumentError{Function: call.Function.Name, Err: err}
+	}
+
+	if options.schema != nil {
+		if violations := schema.Validate(*options.schema, value); len(violations) > 0 {
+			argErr := &ArgumentError{Function: call.Function.Name, Violations: violations}
+			for _, v := range violations {
+				switch v.Keyword {
+				case "required":
+					argErr.Missing = append(argErr.Missing, v.Path)
+				case "type":
+					argErr.Mistyped = append(argErr.Mistyped, v.Path)
+				}
+			}
+			return nil, argErr
+		}
+	}
+
+	var args T
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return nil, &ArgumentError{Function: call.Function.Name, Err: err}
+	}
+	return &args, nil
+}
+
+// repairJSON はコードブロックを取り除き、シングルクォートの文字列と末尾のカンマを修正します
+// 有効なJSONであればそのまま返します
+func repairJSON(s string) string {
+	s = stripCodeFence(s)
+	if json.Valid([]byte(s)) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			end, ok := scanString(s, i)
+			if !ok {
+				end = len(s)
+			}
+			b.WriteString(s[i:end])
+			i = end - 1
+		case '\'':
+			i = writeSingleQuoted(&b, s, i)
+		case ',':
+			// 閉じ括弧の直前のカンマは取り除く
+			next := i + 1
+			for next < len(s) && strings.IndexByte(" \t\r\n", s[next]) >= 0 {
+				next++
+			}
+			if next < len(s) && (s[next] == '}' || s[next] == ']') {
+				continue
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// writeSingleQuoted は start から始まるシングルクォートの文字列をダブルクォートの文字列として書き込み、
+// 閉じクォートの位置を返します
+func writeSingleQuoted(b *strings.Builder, s string, start int) int {
+	b.WriteByte('"')
+	i := start + 1
+	for ; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'':
+			b.WriteByte('"')
+			return i
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			b.WriteByte(c)
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return i
+}
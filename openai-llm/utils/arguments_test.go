@@ -0,0 +1,95 @@
+package utils_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+type bookingArgs struct {
+	City   string   `json:"city"`
+	Nights int      `json:"nights"`
+	Guests []string `json:"guests"`
+}
+
+func newToolCall(name, arguments string) *utils.ToolCall {
+	return &utils.ToolCall{ID: "call_1", Type: "function", Function: utils.Function{Name: name, Arguments: arguments}}
+}
+
+func TestDecodeArguments(t *testing.T) {
+	args, err := utils.DecodeArguments[bookingArgs](newToolCall("book", `{"city":"Kyoto","nights":2,"guests":["a","b"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args.City != "Kyoto" || args.Nights != 2 || len(args.Guests) != 2 {
+		t.Errorf("unexpected args: %+v", args)
+	}
+
+	// スキーマを指定しない場合は検証しないため、省略されたフィールドはゼロ値になる
+	args, err = utils.DecodeArguments[bookingArgs](newToolCall("book", `{"city":"Kyoto"}`))
+	if err != nil || args.City != "Kyoto" || args.Guests != nil {
+		t.Errorf("unexpected result without schema: %+v, %v", args, err)
+	}
+
+	weather, err := utils.DecodeArguments[schema.WeatherFunctionArgs](
+		newToolCall("weather", `{"location":"Tokyo"}`),
+		utils.WithTool(*schema.NewWeatherFunctionCallSchema()),
+	)
+	if err != nil || weather.Location != "Tokyo" {
+		t.Errorf("unexpected result: %+v, %v", weather, err)
+	}
+}
+
+func TestDecodeArgumentsViolations(t *testing.T) {
+	bookingSchema := utils.WithArgumentSchema(schema.MustFrom[bookingArgs]())
+	_, err := utils.DecodeArguments[bookingArgs](newToolCall("book", `{"city":"Kyoto","nights":"two","guests":["a",3]}`), bookingSchema)
+
+	var argErr *utils.ArgumentError
+	if !errors.As(err, &argErr) {
+		t.Fatalf("expected *ArgumentError, got %T: %v", err, err)
+	}
+	if argErr.Function != "book" || len(argErr.Missing) != 0 {
+		t.Errorf("unexpected error: %+v", argErr)
+	}
+	slices.Sort(argErr.Mistyped)
+	if !slices.Equal(argErr.Mistyped, []string{"$.guests[1]", "$.nights"}) {
+		t.Errorf("unexpected mistyped fields: %v", argErr.Mistyped)
+	}
+
+	_, err = utils.DecodeArguments[bookingArgs](newToolCall("book", `{"nights":1.5}`), bookingSchema)
+	if !errors.As(err, &argErr) {
+		t.Fatalf("expected *ArgumentError, got %T: %v", err, err)
+	}
+	slices.Sort(argErr.Missing)
+	if !slices.Equal(argErr.Missing, []string{"$.city", "$.guests"}) || !slices.Equal(argErr.Mistyped, []string{"$.nights"}) {
+		t.Errorf("unexpected violations: missing=%v mistyped=%v", argErr.Missing, argErr.Mistyped)
+	}
+}
+
+func TestDecodeArgumentsLenient(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments string
+	}{
+		{"code fence", "```json\n{\"city\": \"Kyoto\", \"nights\": 2, \"guests\": []}\n```"},
+		{"single quotes", `{'city': 'Kyo"to', 'nights': 2, 'guests': ['it\'s me']}`},
+		{"trailing commas", `{"city": "Kyoto", "nights": 2, "guests": ["a", "b",],}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := utils.DecodeArguments[bookingArgs](newToolCall("book", tt.arguments)); err == nil {
+				t.Error("expected strict decoding to fail")
+			}
+			args, err := utils.DecodeArguments[bookingArgs](newToolCall("book", tt.arguments), utils.WithLenient())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if args.Nights != 2 {
+				t.Errorf("unexpected args: %+v", args)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultAzureAPIVersion はAzure OpenAIのデフォルトのAPIバージョンです
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// TokenSource はMicrosoft Entra IDなどのアクセストークンを提供します
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc は関数をTokenSourceとして利用するための型です
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// AzureConfig はAzure OpenAIのデプロイメントへの接続情報を定義します
+type AzureConfig struct {
+	// Endpoint は https://{resource}.openai.azure.com のようなリソースのURLです
+	Endpoint string
+	// Deployment はデプロイメント名です（空の場合は ClientConfig.Model を使用します）
+	Deployment string
+	// APIVersion は api-version クエリパラメータの値です
+	APIVersion string
+	// TokenSource を指定した場合は api-key ヘッダーの代わりにBearerトークンで認証します
+	TokenSource TokenSource
+}
+
+// NewAzureClientConfig はAzure OpenAIのデプロイメント向けのClientConfigを作成します
+// apiKey は api-key ヘッダーで送信されます
+func NewAzureClientConfig(endpoint, deployment, apiKey string) *ClientConfig {
+	return &ClientConfig{
+		APIKey: apiKey,
+		Model:  deployment,
+		Client: &http.Client{},
+		Azure: &AzureConfig{
+			Endpoint:   endpoint,
+			Deployment: deployment,
+			APIVersion: DefaultAzureAPIVersion,
+		},
+	}
+}
+
+// azureEndpoint はAzure OpenAIのAPIのURLを組み立てます
+func (c *Client) azureEndpoint(path string) string {
+	azure := c.config.Azure
+	base := strings.TrimRight(azure.Endpoint, "/") + "/openai"
+	if path != "/models" {
+		deployment := azure.Deployment
+		if deployment == "" {
+			deployment = c.config.Model
+		}
+		base += "/deployments/" + url.PathEscape(deployment)
+	}
+
+	version := azure.APIVersion
+	if version == "" {
+		version = DefaultAzureAPIVersion
+	}
+	return base + path + "?api-version=" + url.QueryEscape(version)
+}
+
+// authorize はリクエストに認証ヘッダーを設定します
+func (c *Client) authorize(ctx context.Context, req *http.Request) error {
+	if azure := c.config.Azure; azure != nil {
+		if azure.TokenSource != nil {
+			token, err := azure.TokenSource.Token(ctx)
+			if err != nil {
+				return fmt.Errorf("error getting token: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		}
+		req.Header.Set("api-key", c.config.APIKey)
+		return nil
+	}
+
+	if c.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+	return nil
+}
+
+// ContentFilterResults はAzure OpenAIのコンテンツフィルターの判定結果です
+type ContentFilterResults struct {
+	Hate                  *ContentFilterSeverity  `json:"hate,omitempty"`
+	SelfHarm              *ContentFilterSeverity  `json:"self_harm,omitempty"`
+	Sexual                *ContentFilterSeverity  `json:"sexual,omitempty"`
+	Violence              *ContentFilterSeverity  `json:"violence,omitempty"`
+	Jailbreak             *ContentFilterDetection `json:"jailbreak,omitempty"`
+	Profanity             *ContentFilterDetection `json:"profanity,omitempty"`
+	ProtectedMaterialText *ContentFilterDetection `json:"protected_material_text,omitempty"`
+	ProtectedMaterialCode *ContentFilterDetection `json:"protected_material_code,omitempty"`
+	Error                 *ContentFilterError     `json:"error,omitempty"`
+}
+
+// ContentFilterSeverity は重大度で判定されるカテゴリの結果です
+type ContentFilterSeverity struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity"`
+}
+
+// ContentFilterDetection は検出の有無で判定されるカテゴリの結果です
+type ContentFilterDetection struct {
+	Filtered bool `json:"filtered"`
+	Detected bool `json:"detected"`
+}
+
+// ContentFilterError はコンテンツフィルターの実行に失敗した場合のエラーです
+type ContentFilterError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PromptFilterResult はプロンプトに対するコンテンツフィルターの判定結果です
+type PromptFilterResult struct {
+	PromptIndex          int                  `json:"prompt_index"`
+	ContentFilterResults ContentFilterResults `json:"content_filter_results"`
+}
+
+// FilteredCategories はフィルターされたカテゴリ名を返します
+func (r *ContentFilterResults) FilteredCategories() []string {
+	if r == nil {
+		return nil
+	}
+
+	var categories []string
+	severities := []struct {
+		name   string
+		result *ContentFilterSeverity
+	}{
+		{"hate", r.Hate},
+		{"self_harm", r.SelfHarm},
+		{"sexual", r.Sexual},
+		{"violence", r.Violence},
+	}
+	for _, s := range severities {
+		if s.result != nil && s.result.Filtered {
+			categories = append(categories, s.name)
+		}
+	}
+
+	detections := []struct {
+		name   string
+		result *ContentFilterDetection
+	}{
+		{"jailbreak", r.Jailbreak},
+		{"profanity", r.Profanity},
+		{"protected_material_text", r.ProtectedMaterialText},
+		{"protected_material_code", r.ProtectedMaterialCode},
+	}
+	for _, d := range detections {
+		if d.result != nil && d.result.Filtered {
+			categories = append(categories, d.name)
+		}
+	}
+	return categories
+}
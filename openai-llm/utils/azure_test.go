@@ -0,0 +1,93 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+const azureFilteredBody = `{
+	"id": "chatcmpl-az",
+	"prompt_filter_results": [{"prompt_index": 0, "content_filter_results": {"hate": {"filtered": false, "severity": "safe"}, "jailbreak": {"filtered": false, "detected": false}}}],
+	"choices": [{
+		"index": 0,
+		"finish_reason": "content_filter",
+		"message": {"role": "assistant", "content": null},
+		"content_filter_results": {"violence": {"filtered": true, "severity": "high"}, "sexual": {"filtered": false, "severity": "safe"}}
+	}]
+}`
+
+func TestAzureDeploymentRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/gpt-4o-prod/chat/completions" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("api-version"); got != "2024-06-01" {
+			t.Errorf("unexpected api-version: %s", got)
+		}
+		if r.Header.Get("api-key") != "azure-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("expected api-key authentication, got %v", r.Header)
+		}
+		io.WriteString(w, azureFilteredBody)
+	}))
+	defer server.Close()
+
+	config := utils.NewAzureClientConfig(server.URL+"/", "gpt-4o-prod", "azure-key")
+	config.Azure.APIVersion = "2024-06-01"
+	client := utils.NewClient(config)
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	}
+	res, err := client.SendRequestWithFunctionCall(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.PromptFilterResults) != 1 || res.PromptFilterResults[0].ContentFilterResults.Hate.Severity != "safe" {
+		t.Errorf("unexpected prompt filter results: %+v", res.PromptFilterResults)
+	}
+	filter := res.Choices[0].ContentFilterResults
+	if filter == nil || !filter.Violence.Filtered || filter.Violence.Severity != "high" {
+		t.Errorf("unexpected content filter results: %+v", filter)
+	}
+
+	structured, err := client.SendRequestWithStructuredOutput(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = utils.HandleResponse[struct{}](structured)
+	if !errors.Is(err, utils.ErrContentFilter) {
+		t.Fatalf("expected ContentFilter error, got %v", err)
+	}
+	if got := err.Error(); got != "ContentFilter: the response was filtered due to content restrictions (violence)" {
+		t.Errorf("unexpected error message: %s", got)
+	}
+}
+
+func TestAzureTokenSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer entra-token" || r.Header.Get("api-key") != "" {
+			t.Errorf("expected bearer token authentication, got %v", r.Header)
+		}
+		io.WriteString(w, azureFilteredBody)
+	}))
+	defer server.Close()
+
+	config := utils.NewAzureClientConfig(server.URL, "gpt-4o-prod", "")
+	config.Azure.TokenSource = utils.TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return "entra-token", nil
+	})
+	client := utils.NewClient(config)
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	}
+	if _, err := client.SendRequestWithFunctionCall(opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+)
+
+// Metadata は構造化出力のレスポンスに付随する情報です
+// 修復のために再要求した場合、ID などは最後の試行のもので、Usage は全ての試行の合計です
+type Metadata struct {
+	ID                string
+	Model             string
+	SystemFingerprint string
+	FinishReason      string
+	Usage             Usage
+	// Attempts は各試行の結果です（修復しない場合は1件です）
+	Attempts []Attempt
+}
+
+// Attempt は構造化出力の1回の試行を表します
+type Attempt struct {
+	ID           string
+	FinishReason string
+	Usage        Usage
+	// Err はレスポンスをパースまたは検証できなかった場合のエラーです
+	Err error
+}
+
+// CompleteOption は Complete の動作を変更するオプションです
+type CompleteOption func(*completeOptions)
+
+type completeOptions struct {
+	name        string
+	description string
+	parameters  *Parameters
+	repairs     int
+}
+
+// WithSchemaName はリクエストに含めるスキーマ名を指定します
+func WithSchemaName(name string) CompleteOption {
+	return func(o *completeOptions) {
+		o.name = name
+	}
+}
+
+// WithSchemaDescription はリクエストに含めるスキーマの説明を指定します
+func WithSchemaDescription(description string) CompleteOption {
+	return func(o *completeOptions) {
+		o.description = description
+	}
+}
+
+// WithParameters はリクエストの生成パラメーターを指定します
+func WithParameters(parameters *Parameters) CompleteOption {
+	return func(o *completeOptions) {
+		o.parameters = parameters
+	}
+}
+
+// WithRepair はレスポンスをパースできない場合やスキーマに従っていない場合に、
+// エラーの内容をモデルに伝えて最大 n 回まで再要求します
+// 有効にするとレスポンスは HandleResponse の WithSchemaValidation で検証されます
+func WithRepair(n int) CompleteOption {
+	return func(o *completeOptions) {
+		o.repairs = n
+	}
+}
+
+// Complete は型 T からスキーマを生成して構造化出力のリクエストを送信し、パースした結果を返します
+// エラーの場合でもレスポンスを受信していればMetadataを返します
+func Complete[T any](ctx context.Context, c *Client, messages []Message, opts ...CompleteOption) (*T, *Metadata, error) {
+	if len(messages) == 0 {
+		return nil, nil, fmt.Errorf("at least one message is required")
+	}
+
+	responseSchema, err := schema.NewResponseSchema[T]()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating schema: %w", err)
+	}
+	var options completeOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.name != "" {
+		responseSchema.Name = options.name
+	}
+	responseSchema.Description = options.description
+
+	schemaJSON, err := json.Marshal(responseSchema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error marshalling schema: %v", err)
+	}
+
+	var handleOpts []HandleOption
+	if options.repairs > 0 {
+		handleOpts = append(handleOpts, WithSchemaValidation(responseSchema.Schema))
+	}
+
+	var metadata *Metadata
+	for attempt := 0; ; attempt++ {
+		apiResp, err := c.structuredOutput(ctx, RequestOptions{
+			Messages:   messages,
+			Schema:     schemaJSON,
+			Parameters: options.parameters,
+		})
+		if err != nil {
+			return nil, metadata, err
+		}
+
+		current := responseMetadata(apiResp)
+
+		result, err := HandleResponse[T](apiResp, handleOpts...)
+		metadata = mergeMetadata(metadata, current, err)
+		if err == nil {
+			return result, metadata, nil
+		}
+		if attempt >= options.repairs || !isRepairable(err) {
+			return nil, metadata, err
+		}
+		messages = append(slices.Clip(messages), repairMessages(apiResp, err)...)
+	}
+}
+
+// mergeMetadata はこれまでの試行のMetadataに新しい試行の結果を加えます
+func mergeMetadata(previous, current *Metadata, err error) *Metadata {
+	attempt := Attempt{
+		ID:           current.ID,
+		FinishReason: current.FinishReason,
+		Usage:        current.Usage,
+		Err:          err,
+	}
+	if previous != nil {
+		current.Usage = previous.Usage.Add(current.Usage)
+		current.Attempts = previous.Attempts
+	}
+	current.Attempts = append(current.Attempts, attempt)
+	return current
+}
+
+// isRepairable は再要求によって解消する可能性のあるエラーかを判定します
+func isRepairable(err error) bool {
+	return errors.Is(err, ErrParse) || errors.Is(err, ErrSchemaViolation)
+}
+
+// repairMessages はモデルの回答とエラーの内容を伝えるメッセージを作成します
+func repairMessages(resp *APIResponse, err error) []Message {
+	var content string
+	if len(resp.Choices) > 0 {
+		content = resp.Choices[0].Message.Text()
+	}
+
+	var problems string
+	var violation *SchemaViolation
+	if errors.As(err, &violation) {
+		lines := make([]string, 0, len(violation.Violations))
+		for _, v := range violation.Violations {
+			lines = append(lines, "- "+v.String())
+		}
+		problems = strings.Join(lines, "\n")
+	} else {
+		problems = "- " + err.Error()
+	}
+
+	return []Message{
+		NewMessage(RoleAssistant, content),
+		NewMessage(RoleUser, "Your previous response did not match the required JSON schema:\n"+problems+"\n\nRespond again with only a corrected JSON object that matches the schema."),
+	}
+}
+
+// responseMetadata はレスポンスからMetadataを作成します
+func responseMetadata(resp *APIResponse) *Metadata {
+	metadata := &Metadata{
+		ID:                resp.ID,
+		Model:             resp.Model,
+		SystemFingerprint: resp.SystemFingerprint,
+		Usage:             resp.Usage,
+	}
+	if len(resp.Choices) > 0 {
+		metadata.FinishReason = resp.Choices[0].FinishReason
+	}
+	return metadata
+}
@@ -0,0 +1,141 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+func TestComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResponseFormat struct {
+				Type       string                `json:"type"`
+				JSONSchema schema.ResponseSchema `json:"json_schema"`
+			} `json:"response_format"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if body.ResponseFormat.Type != "json_schema" {
+			t.Errorf("unexpected response_format type: %s", body.ResponseFormat.Type)
+		}
+		if got := body.ResponseFormat.JSONSchema; got.Name != "weather_response" || !got.Strict {
+			t.Errorf("unexpected json_schema: %+v", got)
+		}
+		if got := body.ResponseFormat.JSONSchema.Schema.Properties["unit"].Enum; len(got) != 2 {
+			t.Errorf("expected unit enum in schema, got %v", got)
+		}
+
+		w.Write([]byte(`{
+			"id": "chatcmpl-9",
+			"model": "gpt-4o-2024-08-06",
+			"system_fingerprint": "fp_1",
+			"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "{\"location\":\"Tokyo\",\"temperature\":21.5,\"unit\":\"C\",\"conditions\":\"Sunny\"}"}}],
+			"usage": {"prompt_tokens": 40, "completion_tokens": 12, "total_tokens": 52}
+		}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	messages := []utils.Message{
+		utils.NewMessage(utils.RoleUser, "What's the weather like in Tokyo today?"),
+	}
+	weather, metadata, err := utils.Complete[schema.WeatherResponse](context.Background(), client, messages)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if weather.Location != "Tokyo" || weather.Temperature != 21.5 || weather.Unit != "C" {
+		t.Errorf("unexpected result: %+v", weather)
+	}
+	if metadata.ID != "chatcmpl-9" || metadata.Model != "gpt-4o-2024-08-06" || metadata.FinishReason != "stop" {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+	if metadata.Usage.TotalTokens != 52 {
+		t.Errorf("unexpected usage: %+v", metadata.Usage)
+	}
+}
+
+func TestCompleteWithRepair(t *testing.T) {
+	responses := []string{
+		`{"location":"Tokyo","temperature":21.5,"unit":"K"}`,
+		`{"location":"Tokyo","temperature":21.5,"unit":"C","conditions":"Sunny"}`,
+	}
+	var requests [][]map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []map[string]any `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		requests = append(requests, body.Messages)
+
+		content, _ := json.Marshal(responses[min(len(requests), len(responses))-1])
+		fmt.Fprintf(w, `{
+			"id": "chatcmpl-%d",
+			"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": %s}}],
+			"usage": {"prompt_tokens": 40, "completion_tokens": 10, "total_tokens": 50}
+		}`, len(requests), content)
+	}))
+	defer server.Close()
+
+	messages := []utils.Message{utils.NewMessage(utils.RoleUser, "What's the weather like in Tokyo today?")}
+	weather, metadata, err := utils.Complete[schema.WeatherResponse](context.Background(), newTestClient(server), messages, utils.WithRepair(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if weather.Unit != "C" || weather.Conditions != "Sunny" {
+		t.Errorf("unexpected result: %+v", weather)
+	}
+
+	if len(requests) != 2 || len(requests[1]) != 3 {
+		t.Fatalf("expected a follow-up request with 3 messages, got %v", requests)
+	}
+	if requests[1][1]["role"] != "assistant" || requests[1][1]["content"] != responses[0] {
+		t.Errorf("expected the invalid answer to be sent back, got %v", requests[1][1])
+	}
+	feedback, _ := requests[1][2]["content"].(string)
+	if !strings.Contains(feedback, "$.unit") || !strings.Contains(feedback, "$.conditions") {
+		t.Errorf("expected violations in feedback, got %q", feedback)
+	}
+	if len(messages) != 1 {
+		t.Errorf("caller's messages must not be modified, got %d", len(messages))
+	}
+
+	if len(metadata.Attempts) != 2 || metadata.Attempts[0].Err == nil || metadata.Attempts[1].Err != nil {
+		t.Errorf("unexpected attempts: %+v", metadata.Attempts)
+	}
+	if metadata.ID != "chatcmpl-2" || metadata.Usage.TotalTokens != 100 || metadata.Attempts[0].Usage.TotalTokens != 50 {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+}
+
+func TestCompleteWithRepairExhausted(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{
+			"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "not json"}}],
+			"usage": {"total_tokens": 5}
+		}`))
+	}))
+	defer server.Close()
+
+	messages := []utils.Message{utils.NewMessage(utils.RoleUser, "hello")}
+	_, metadata, err := utils.Complete[schema.WeatherResponse](context.Background(), newTestClient(server), messages, utils.WithRepair(1))
+	if !errors.Is(err, utils.ErrParse) {
+		t.Fatalf("expected ErrParse, got %v", err)
+	}
+	if calls != 2 || len(metadata.Attempts) != 2 || metadata.Usage.TotalTokens != 10 {
+		t.Errorf("expected 2 attempts, got calls=%d metadata=%+v", calls, metadata)
+	}
+}
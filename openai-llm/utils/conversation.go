@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+)
+
+// Conversation は複数ターンの会話の履歴を管理します
+// JSONにエンコードして保存し、デコードして会話を再開できます
+type Conversation struct {
+	// ID は Send と Run のコストを CostTracker で会話ごとに集計するためのIDです（空の場合は集計しません）
+	ID string `json:"id,omitempty"`
+	// Messages はシステムプロンプトを先頭に含む会話の履歴です
+	Messages []Message `json:"messages"`
+	// Tools は Send でモデルに渡すツールです
+	Tools []schema.Tool `json:"tools,omitempty"`
+	// Parameters は Send で使用する生成パラメーターです
+	Parameters *Parameters `json:"parameters,omitempty"`
+}
+
+// NewConversation はシステムプロンプトを指定してConversationを作成します（空の場合はシステムプロンプトなし）
+func NewConversation(systemPrompt string) *Conversation {
+	c := &Conversation{}
+	c.SetSystem(systemPrompt)
+	return c
+}
+
+// System は現在のシステムプロンプトを返します
+func (c *Conversation) System() string {
+	if len(c.Messages) == 0 || c.Messages[0].Role != RoleSystem {
+		return ""
+	}
+	var prompt string
+	if err := json.Unmarshal(c.Messages[0].Content, &prompt); err != nil {
+		return ""
+	}
+	return prompt
+}
+
+// SetSystem はシステムプロンプトを置き換えます（空の場合は削除します）
+// 以降の履歴はそのまま残ります
+func (c *Conversation) SetSystem(prompt string) {
+	hasSystem := len(c.Messages) > 0 && c.Messages[0].Role == RoleSystem
+	switch {
+	case prompt == "" && hasSystem:
+		c.Messages = slices.Delete(slices.Clone(c.Messages), 0, 1)
+	case prompt == "":
+	case hasSystem:
+		c.Messages = slices.Clone(c.Messages)
+		c.Messages[0] = NewMessage(RoleSystem, prompt)
+	default:
+		c.Messages = slices.Insert(slices.Clone(c.Messages), 0, NewMessage(RoleSystem, prompt))
+	}
+}
+
+// Add はメッセージを履歴に追加します
+func (c *Conversation) Add(messages ...Message) {
+	c.Messages = append(c.Messages, messages...)
+}
+
+// AddUser はユーザーのメッセージを履歴に追加します
+func (c *Conversation) AddUser(content string) {
+	c.Add(NewMessage(RoleUser, content))
+}
+
+// AddReply はアシスタントの応答（ツール呼び出しを含む）を履歴に追加します
+func (c *Conversation) AddReply(reply ChatMessage) {
+	c.Add(reply.ToMessage())
+}
+
+// AddToolResult はツール呼び出しの結果を履歴に追加します
+// 文字列はそのまま、それ以外の値はJSONにエンコードして追加されます
+func (c *Conversation) AddToolResult(toolCallID string, result any) error {
+	content, ok := result.(string)
+	if !ok {
+		resultJSON, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("error marshalling result of tool call %s: %v", toolCallID, err)
+		}
+		content = string(resultJSON)
+	}
+	c.Add(NewToolMessage(toolCallID, content))
+	return nil
+}
+
+// PendingToolCalls は最後のアシスタントの応答に含まれるツール呼び出しのうち、結果がまだ追加されていないものを返します
+func (c *Conversation) PendingToolCalls() []ToolCall {
+	answered := make(map[string]bool)
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		message := c.Messages[i]
+		switch message.Role {
+		case RoleTool:
+			answered[message.ToolCallID] = true
+		case RoleAssistant:
+			var pending []ToolCall
+			for _, call := range message.ToolCalls {
+				if !answered[call.ID] {
+					pending = append(pending, call)
+				}
+			}
+			return pending
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// Fork は履歴を複製したConversationを返します
+// 複製した会話への追加は元の会話に影響しません
+// ID は引き継がれるため、複製した会話のコストは元の会話と合わせて集計されます
+func (c *Conversation) Fork() *Conversation {
+	return &Conversation{
+		ID:         c.ID,
+		Messages:   slices.Clone(c.Messages),
+		Tools:      slices.Clone(c.Tools),
+		Parameters: c.Parameters.clone(),
+	}
+}
+
+// Options は履歴とツールから次のターンのRequestOptionsを作成します
+func (c *Conversation) Options() (RequestOptions, error) {
+	opts := RequestOptions{Messages: c.Messages, Parameters: c.Parameters}
+	if len(c.Tools) > 0 {
+		toolsJSON, err := json.Marshal(c.Tools)
+		if err != nil {
+			return RequestOptions{}, fmt.Errorf("error marshalling tools: %v", err)
+		}
+		opts.Schema = toolsJSON
+	}
+	return opts, nil
+}
+
+// Send は履歴を送信し、アシスタントの応答を履歴に追加します
+// エラーの場合、履歴は変更されません
+func (c *Conversation) Send(ctx context.Context, client *Client) (*ChatCompletion, error) {
+	opts, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	completion, err := client.SendRequestWithFunctionCallContext(c.context(ctx), opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(completion.Choices) == 0 {
+		return completion, fmt.Errorf("no choices available")
+	}
+	c.AddReply(completion.Choices[0].Message)
+	return completion, nil
+}
+
+// Run は ToolRunner で履歴を送信し、ツールの実行が終わるまでの応答とツールの結果を履歴に追加します
+// ツールは Tools ではなく ToolRunner.Registry のものが使用されます
+// Send と同じく、エラーの場合（ErrMaxIterations を含む）履歴は変更されません
+// 途中までの履歴は戻り値の RunResult.Messages から取得できます
+func (c *Conversation) Run(ctx context.Context, runner *ToolRunner) (*RunResult, error) {
+	result, err := runner.Run(c.context(ctx), c.Messages)
+	if err != nil {
+		return result, err
+	}
+	c.Messages = result.Messages
+	return result, nil
+}
+
+// context は ID をコストの集計に使用する会話のIDとして設定したコンテキストを返します
+func (c *Conversation) context(ctx context.Context) context.Context {
+	if c.ID == "" {
+		return ctx
+	}
+	return WithConversationID(ctx, c.ID)
+}
@@ -0,0 +1,156 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+func TestConversation(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body utils.RequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+			return
+		}
+
+		switch calls.Add(1) {
+		case 1:
+			if len(body.Messages) != 2 || body.Tools == nil {
+				t.Errorf("expected system and user messages with tools, got %+v", body)
+			}
+			w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":null,"tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"location\":\"Tokyo\"}"}}
+			]}}]}`))
+		case 2:
+			if len(body.Messages) != 4 {
+				t.Errorf("expected 4 messages, got %d", len(body.Messages))
+				return
+			}
+			if got := body.Messages[3]; got.Role != utils.RoleTool || got.ToolCallID != "call_1" || string(got.Content) != `"{\"temperature\":20}"` {
+				t.Errorf("unexpected tool message: %+v", got)
+			}
+			w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"It is 20 degrees in Tokyo."}}]}`))
+		default:
+			t.Errorf("unexpected request %d", calls.Load())
+		}
+	}))
+	defer server.Close()
+	config := utils.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	client := utils.NewClient(config)
+
+	conv := utils.NewConversation("You are a helpful assistant.")
+	conv.Tools = []schema.Tool{*schema.NewWeatherFunctionCallSchema()}
+	conv.AddUser("What's the weather like in Tokyo?")
+
+	ctx := context.Background()
+	if _, err := conv.Send(ctx, client); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pending := conv.PendingToolCalls()
+	if len(pending) != 1 || pending[0].ID != "call_1" {
+		t.Fatalf("expected pending tool call, got %+v", pending)
+	}
+	if err := conv.AddToolResult("call_1", map[string]int{"temperature": 20}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pending := conv.PendingToolCalls(); len(pending) != 0 {
+		t.Errorf("expected no pending tool calls, got %+v", pending)
+	}
+
+	if _, err := conv.Send(ctx, client); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	last := conv.Messages[len(conv.Messages)-1]
+	if last.Role != utils.RoleAssistant || string(last.Content) != `"It is 20 degrees in Tokyo."` {
+		t.Errorf("unexpected reply: %+v", last)
+	}
+
+	fork := conv.Fork()
+	fork.SetSystem("Answer in Japanese.")
+	fork.AddUser("Thanks!")
+	if conv.System() != "You are a helpful assistant." || len(conv.Messages) != 5 {
+		t.Errorf("expected fork not to change the original, got %q with %d messages", conv.System(), len(conv.Messages))
+	}
+	if fork.System() != "Answer in Japanese." || len(fork.Messages) != 6 {
+		t.Errorf("unexpected fork: %q with %d messages", fork.System(), len(fork.Messages))
+	}
+	fork.SetSystem("")
+	if fork.System() != "" || fork.Messages[0].Role != utils.RoleUser {
+		t.Errorf("expected system prompt to be removed, got %+v", fork.Messages[0])
+	}
+
+	data, err := json.Marshal(conv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var restored utils.Conversation
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, _ := json.Marshal(&restored)
+	if string(again) != string(data) || !reflect.DeepEqual(restored.Messages[2].ToolCalls, conv.Messages[2].ToolCalls) {
+		t.Errorf("expected round trip to preserve the conversation\nwant: %s\ngot:  %s", data, again)
+	}
+}
+
+func TestConversationForkCopiesParameters(t *testing.T) {
+	conv := utils.NewConversation("")
+	conv.Parameters = &utils.Parameters{
+		Stop:       []string{"END"},
+		LogitBias:  map[string]int{"50256": -100},
+		Metadata:   map[string]string{"pipeline": "test"},
+		ToolChoice: utils.ToolChoiceRequired(),
+	}
+
+	fork := conv.Fork()
+	fork.Parameters.Stop[0] = "STOP"
+	fork.Parameters.LogitBias["50256"] = 100
+	fork.Parameters.Metadata["pipeline"] = "fork"
+	fork.Parameters.ToolChoice.Mode = "none"
+
+	p := conv.Parameters
+	if p.Stop[0] != "END" || p.LogitBias["50256"] != -100 || p.Metadata["pipeline"] != "test" || p.ToolChoice.Mode != "required" {
+		t.Errorf("expected fork not to change the original parameters, got %+v", p)
+	}
+}
+
+func TestConversationRunKeepsHistoryOnError(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"location\":\"Tokyo\"}"}}
+			]}}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"bad request"}}`))
+	}))
+	defer server.Close()
+
+	conv := utils.NewConversation("You are a helpful assistant.")
+	conv.AddUser("Weather in Tokyo?")
+	before := slices.Clone(conv.Messages)
+
+	result, err := conv.Run(context.Background(), utils.NewToolRunner(newTestClient(server), newWeatherRegistry(t)))
+	if err == nil {
+		t.Fatal("expected the second request to fail")
+	}
+	if !reflect.DeepEqual(conv.Messages, before) {
+		t.Errorf("expected history to be unchanged on error, got %d messages", len(conv.Messages))
+	}
+	if result == nil || len(result.Messages) != 4 {
+		t.Errorf("expected the partial transcript in the result, got %+v", result)
+	}
+}
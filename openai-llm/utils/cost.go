@@ -0,0 +1,309 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/yuki5155/go-llms/openai-llm/models"
+)
+
+// ErrBudgetExceeded はコストの上限に達したため、リクエストを送信しなかった場合のエラーです
+var ErrBudgetExceeded = errors.New("cost budget exceeded")
+
+// Cost はトークン使用量の料金（USD）の内訳です
+type Cost struct {
+	// Input はキャッシュされていない入力トークンの料金です
+	Input       float64 `json:"input"`
+	CachedInput float64 `json:"cached_input"`
+	// CacheWrite はキャッシュに書き込まれた入力トークンの料金です
+	CacheWrite float64 `json:"cache_write,omitempty"`
+	// Output は推論を除く出力トークンの料金です
+	Output    float64 `json:"output"`
+	Reasoning float64 `json:"reasoning"`
+}
+
+// Total は料金の合計を返します
+func (c Cost) Total() float64 {
+	return c.Input + c.CachedInput + c.CacheWrite + c.Output + c.Reasoning
+}
+
+// Add は2つのCostを合算したCostを返します
+func (c Cost) Add(other Cost) Cost {
+	return Cost{
+		Input:       c.Input + other.Input,
+		CachedInput: c.CachedInput + other.CachedInput,
+		CacheWrite:  c.CacheWrite + other.CacheWrite,
+		Output:      c.Output + other.Output,
+		Reasoning:   c.Reasoning + other.Reasoning,
+	}
+}
+
+// CostBreakdown はモデルの料金からトークン使用量の料金の内訳を計算します
+// 推論トークンは出力トークンに含まれるものとして、出力の料金で計算します
+func (u Usage) CostBreakdown(m models.Model) Cost {
+	const perToken = 1.0 / 1_000_000
+	cachedPrice := m.Pricing.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = m.Pricing.Input
+	}
+	cacheWritePrice := m.Pricing.CacheWrite
+	if cacheWritePrice == 0 {
+		cacheWritePrice = m.Pricing.Input
+	}
+	cached := u.PromptTokensDetails.CachedTokens
+	cacheWrite := u.PromptTokensDetails.CacheWriteTokens
+	reasoning := u.CompletionTokensDetails.ReasoningTokens
+	return Cost{
+		Input:       float64(max(u.PromptTokens-cached-cacheWrite, 0)) * m.Pricing.Input * perToken,
+		CachedInput: float64(cached) * cachedPrice * perToken,
+		CacheWrite:  float64(cacheWrite) * cacheWritePrice * perToken,
+		Output:      float64(max(u.CompletionTokens-reasoning, 0)) * m.Pricing.Output * perToken,
+		Reasoning:   float64(reasoning) * m.Pricing.Output * perToken,
+	}
+}
+
+// Cost はモデルの料金からトークン使用量の料金（USD）を計算します
+func (u Usage) Cost(m models.Model) float64 {
+	return u.CostBreakdown(m).Total()
+}
+
+// Spend は集計したリクエスト数、トークン使用量、料金です
+type Spend struct {
+	Requests int   `json:"requests"`
+	Usage    Usage `json:"usage"`
+	Cost     Cost  `json:"cost"`
+	// Unpriced はレジストリに料金が登録されていないモデルへのリクエスト数です（料金は0として集計されます）
+	Unpriced int `json:"unpriced,omitempty"`
+}
+
+func (s Spend) add(usage Usage, cost Cost, priced bool) Spend {
+	s.Requests++
+	s.Usage = s.Usage.Add(usage)
+	s.Cost = s.Cost.Add(cost)
+	if !priced {
+		s.Unpriced++
+	}
+	return s
+}
+
+// Budget はコストの上限（USD）です（0の場合は上限なし）
+// 上限に達した後のリクエストは送信前に ErrBudgetExceeded で失敗します
+type Budget struct {
+	Total float64
+	// PerClient はクライアントごとの上限です
+	PerClient float64
+	// PerTag はタグごとの上限です
+	PerTag float64
+	// PerConversation は会話ごとの上限です
+	PerConversation float64
+}
+
+// CostTracker はリクエストのトークン使用量を料金に換算し、クライアント・タグ・会話・モデルごとに集計します
+// 複数のクライアントで共有でき、ゼロ値のまま使用できます
+type CostTracker struct {
+	// Models は料金を引くためのレジストリです（nilの場合は models.Default()）
+	Models *models.Registry
+	Budget Budget
+
+	mu            sync.Mutex
+	total         Spend
+	clients       map[string]Spend
+	tags          map[string]Spend
+	conversations map[string]Spend
+	models        map[string]Spend
+}
+
+type costTagsKey struct{}
+
+type conversationIDKey struct{}
+
+// WithCostTags はコンテキストにコストを集計するタグを追加します
+// コンテキストに既に付いているタグは残ります
+func WithCostTags(ctx context.Context, tags ...string) context.Context {
+	existing, _ := ctx.Value(costTagsKey{}).([]string)
+	return context.WithValue(ctx, costTagsKey{}, append(existing[:len(existing):len(existing)], tags...))
+}
+
+// WithConversationID はコンテキストにコストを集計する会話のIDを設定します
+func WithConversationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, conversationIDKey{}, id)
+}
+
+func costTags(ctx context.Context) []string {
+	tags, _ := ctx.Value(costTagsKey{}).([]string)
+	return tags
+}
+
+func conversationID(ctx context.Context) string {
+	id, _ := ctx.Value(conversationIDKey{}).(string)
+	return id
+}
+
+// Allow はクライアントとコンテキストのタグ・会話が上限に達していないかを確認します
+// 上限に達している場合は ErrBudgetExceeded をラップしたエラーを返します
+func (t *CostTracker) Allow(ctx context.Context, client string) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := checkLimit("total", "", t.total, t.Budget.Total); err != nil {
+		return err
+	}
+	if err := checkLimit("client", client, t.clients[client], t.Budget.PerClient); err != nil {
+		return err
+	}
+	for _, tag := range costTags(ctx) {
+		if err := checkLimit("tag", tag, t.tags[tag], t.Budget.PerTag); err != nil {
+			return err
+		}
+	}
+	if id := conversationID(ctx); id != "" {
+		if err := checkLimit("conversation", id, t.conversations[id], t.Budget.PerConversation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkLimit(scope, key string, spend Spend, limit float64) error {
+	if limit <= 0 || spend.Cost.Total() < limit {
+		return nil
+	}
+	if key != "" {
+		scope = fmt.Sprintf("%s %q", scope, key)
+	}
+	return fmt.Errorf("%w: %s spent $%.6f of $%.6f", ErrBudgetExceeded, scope, spend.Cost.Total(), limit)
+}
+
+// Record はリクエストのトークン使用量を集計し、その料金を返します
+// model はレスポンスのモデル名（日付付きのスナップショットなど）で、レジストリから料金を引きます
+func (t *CostTracker) Record(ctx context.Context, client, model string, usage Usage) Cost {
+	if t == nil {
+		return Cost{}
+	}
+	registry := t.Models
+	if registry == nil {
+		registry = models.Default()
+	}
+	m, priced := registry.Lookup(model)
+	cost := usage.CostBreakdown(m)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total = t.total.add(usage, cost, priced)
+	record := func(spends *map[string]Spend, key string) {
+		if *spends == nil {
+			*spends = make(map[string]Spend)
+		}
+		(*spends)[key] = (*spends)[key].add(usage, cost, priced)
+	}
+	record(&t.clients, client)
+	record(&t.models, model)
+	for _, tag := range costTags(ctx) {
+		record(&t.tags, tag)
+	}
+	if id := conversationID(ctx); id != "" {
+		record(&t.conversations, id)
+	}
+	return cost
+}
+
+// Total は全てのリクエストの集計を返します
+func (t *CostTracker) Total() Spend {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total
+}
+
+// ByClient はクライアントの名前ごとの集計を返します
+func (t *CostTracker) ByClient() map[string]Spend {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return maps.Clone(t.clients)
+}
+
+// ByTag はタグごとの集計を返します
+func (t *CostTracker) ByTag() map[string]Spend {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return maps.Clone(t.tags)
+}
+
+// ByConversation は会話のIDごとの集計を返します
+func (t *CostTracker) ByConversation() map[string]Spend {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return maps.Clone(t.conversations)
+}
+
+// ByModel はレスポンスのモデル名ごとの集計を返します
+func (t *CostTracker) ByModel() map[string]Spend {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return maps.Clone(t.models)
+}
+
+// Reset は集計を消去します（Budget はそのまま残ります）
+func (t *CostTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total = Spend{}
+	t.clients, t.tags, t.conversations, t.models = nil, nil, nil, nil
+}
+
+// CostSettings はクライアントのコストの集計の設定です
+// 各バックエンドの ClientConfig に埋め込まれ、送信前の上限の確認と送信後の集計を共通化します
+type CostSettings struct {
+	// Name はコストの集計でクライアントを区別するための名前です（空の場合はモデル名）
+	Name string
+	// Costs はリクエストのコストを集計するCostTrackerです（nilの場合は集計しません）
+	// Costs.Budget の上限に達した後のリクエストは ErrBudgetExceeded で失敗します
+	Costs *CostTracker
+}
+
+// Allow はリクエストの送信前に、このクライアントとコンテキストのタグ・会話が上限に達していないかを確認します
+// model は設定のモデル名で、Name が空の場合のクライアントの名前になります
+func (s CostSettings) Allow(ctx context.Context, model string) error {
+	return s.Costs.Allow(ctx, s.clientName(model))
+}
+
+// Record はレスポンスのトークン使用量を集計します
+// responseModel はレスポンスのモデル名で、空の場合は設定のモデル名 model で料金を引きます
+func (s CostSettings) Record(ctx context.Context, model, responseModel string, usage Usage) {
+	if s.Costs == nil {
+		return
+	}
+	if responseModel == "" {
+		responseModel = model
+	}
+	s.Costs.Record(ctx, s.clientName(model), responseModel, usage)
+}
+
+// clientName はコストの集計に使用するクライアントの名前を返します
+func (s CostSettings) clientName(model string) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return model
+}
+
+// recordCost はレスポンスボディのトークン使用量を ClientConfig.Costs に集計します
+func (c *Client) recordCost(ctx context.Context, body []byte) {
+	if c.config.Costs == nil {
+		return
+	}
+	var resp struct {
+		Model string `json:"model"`
+		Usage *Usage `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Usage == nil {
+		return
+	}
+	c.config.CostSettings.Record(ctx, c.config.Model, resp.Model, *resp.Usage)
+}
@@ -0,0 +1,173 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/models"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// usageCost は testCompletionBody の gpt-4o での料金です: (800*2.5 + 200*1.25 + 400*10 + 100*10) / 100万
+const usageCost = 0.00725
+
+func TestStructuredOutputExposesUsage(t *testing.T) {
+	server, _ := newFlakyServer(t, 0, http.StatusOK, nil, "")
+	client := newTestClient(server)
+
+	resp, err := client.SendRequestWithStructuredOutput(utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "weather?")},
+		Schema:   []byte(`{"name":"weather","schema":{"type":"object"}}`),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ID != "chatcmpl-1" || resp.Model != "gpt-4o-2024-08-06" {
+		t.Errorf("unexpected metadata: id=%q model=%q", resp.ID, resp.Model)
+	}
+	if resp.Usage.PromptTokens != 1000 || resp.Usage.PromptTokensDetails.CachedTokens != 200 || resp.Usage.CompletionTokensDetails.ReasoningTokens != 100 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestCostBreakdown(t *testing.T) {
+	usage := utils.Usage{PromptTokens: 1000, CompletionTokens: 500}
+	usage.PromptTokensDetails.CachedTokens = 200
+	usage.CompletionTokensDetails.ReasoningTokens = 100
+	m := models.Model{Pricing: models.Pricing{Input: 2.5, CachedInput: 1.25, Output: 10}}
+
+	cost := usage.CostBreakdown(m)
+	expected := utils.Cost{Input: 0.002, CachedInput: 0.00025, Output: 0.004, Reasoning: 0.001}
+	for name, got := range map[string][2]float64{
+		"input":        {cost.Input, expected.Input},
+		"cached_input": {cost.CachedInput, expected.CachedInput},
+		"output":       {cost.Output, expected.Output},
+		"reasoning":    {cost.Reasoning, expected.Reasoning},
+	} {
+		if math.Abs(got[0]-got[1]) > 1e-12 {
+			t.Errorf("expected %s cost %v, got %v", name, got[1], got[0])
+		}
+	}
+	if math.Abs(usage.Cost(m)-usageCost) > 1e-12 {
+		t.Errorf("expected total %v, got %v", usageCost, usage.Cost(m))
+	}
+
+	noCache := models.Model{Pricing: models.Pricing{Input: 3, Output: 15}}
+	if got := usage.CostBreakdown(noCache).CachedInput; math.Abs(got-0.0006) > 1e-12 {
+		t.Errorf("expected cached tokens to use the input price, got %v", got)
+	}
+
+	// キャッシュへの書き込みは書き込みの料金で計算し、通常の入力からは除く
+	written := utils.Usage{PromptTokens: 1000}
+	written.PromptTokensDetails.CacheWriteTokens = 400
+	claude := models.Model{Pricing: models.Pricing{Input: 3, CacheWrite: 3.75, Output: 15}}
+	if got := written.CostBreakdown(claude); math.Abs(got.Input-0.0018) > 1e-12 || math.Abs(got.CacheWrite-0.0015) > 1e-12 {
+		t.Errorf("expected cache writes at the cache write price, got %+v", got)
+	}
+}
+
+func TestCostTrackerAggregates(t *testing.T) {
+	server, _ := newFlakyServer(t, 0, http.StatusOK, nil, "")
+	tracker := &utils.CostTracker{}
+	newClient := func(name string) *utils.Client {
+		config := utils.NewClientConfig("test-key")
+		config.Endpoint = server.URL
+		config.Name = name
+		config.Costs = tracker
+		return utils.NewClient(config)
+	}
+	summarizer, assistant := newClient("summarizer"), newClient("")
+	messages := []utils.Message{utils.NewMessage(utils.RoleUser, "hello")}
+
+	ctx := utils.WithCostTags(context.Background(), "batch")
+	if _, err := summarizer.SendRequestWithFunctionCallContext(ctx, utils.RequestOptions{Messages: messages}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conv := utils.NewConversation("You are helpful.")
+	conv.ID = "conv-1"
+	conv.AddUser("hello")
+	if _, err := conv.Send(utils.WithCostTags(ctx, "chat"), assistant); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	total := tracker.Total()
+	if total.Requests != 2 || total.Usage.PromptTokens != 2000 || math.Abs(total.Cost.Total()-2*usageCost) > 1e-12 {
+		t.Errorf("unexpected total: %+v", total)
+	}
+	clients := tracker.ByClient()
+	if clients["summarizer"].Requests != 1 || clients[utils.DefaultModel].Requests != 1 {
+		t.Errorf("expected one request per client, got %+v", clients)
+	}
+	tags := tracker.ByTag()
+	if tags["batch"].Requests != 2 || tags["chat"].Requests != 1 {
+		t.Errorf("unexpected tag totals: %+v", tags)
+	}
+	if got := tracker.ByConversation()["conv-1"]; got.Requests != 1 || math.Abs(got.Cost.Total()-usageCost) > 1e-12 {
+		t.Errorf("unexpected conversation total: %+v", got)
+	}
+	if got := tracker.ByModel()["gpt-4o-2024-08-06"]; got.Requests != 2 || got.Unpriced != 0 {
+		t.Errorf("unexpected model total: %+v", got)
+	}
+
+	tracker.Reset()
+	if tracker.Total().Requests != 0 || len(tracker.ByTag()) != 0 {
+		t.Errorf("expected reset to clear totals")
+	}
+}
+
+func TestCostTrackerBudget(t *testing.T) {
+	server, calls := newFlakyServer(t, 0, http.StatusOK, nil, "")
+	config := utils.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	config.Costs = &utils.CostTracker{Budget: utils.Budget{PerConversation: usageCost}}
+	client := utils.NewClient(config)
+	opts := utils.RequestOptions{Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")}}
+
+	ctx := utils.WithConversationID(context.Background(), "conv-1")
+	if _, err := client.SendRequestWithFunctionCallContext(ctx, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := client.SendRequestWithFunctionCallContext(ctx, opts)
+	if !errors.Is(err, utils.ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the second request not to be sent, got %d calls", calls.Load())
+	}
+
+	// 他の会話は上限に達していない
+	if _, err := client.SendRequestWithFunctionCallContext(utils.WithConversationID(context.Background(), "conv-2"), opts); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCostTrackerStream(t *testing.T) {
+	server := newSSEServer(t, []string{
+		`{"id":"chatcmpl-3","model":"gpt-4o-mini-2024-07-18","choices":[{"index":0,"delta":{"role":"assistant","content":"hi"}}]}`,
+		`{"id":"chatcmpl-3","model":"gpt-4o-mini-2024-07-18","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}`,
+	})
+	tracker := &utils.CostTracker{}
+	config := utils.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	config.Costs = tracker
+	stream, err := utils.NewClient(config).StreamRequestWithFunctionCall(context.Background(), utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for {
+		if _, err := stream.Recv(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := tracker.ByModel()["gpt-4o-mini-2024-07-18"]; got.Requests != 1 || got.Usage.TotalTokens != 12 || got.Cost.Total() == 0 {
+		t.Errorf("expected streamed usage to be recorded, got %+v", got)
+	}
+}
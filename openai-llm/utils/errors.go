@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+var (
+	// ErrRequestTimeout はコンテキストの期限切れやHTTPクライアントのタイムアウトでリクエストが中断されたことを示します
+	ErrRequestTimeout = errors.New("request timed out")
+	// ErrRequestCanceled はコンテキストのキャンセルでリクエストが中断されたことを示します
+	ErrRequestCanceled = errors.New("request canceled")
+)
+
+// WrapRequestError は送受信時のエラーをタイムアウトとキャンセルで区別できるようにラップします
+// 他のバックエンドのクライアントも、このパッケージと同じ ErrRequestTimeout・ErrRequestCanceled を返すために使用します
+// 元のエラーもラップされるため、errors.Is(err, context.DeadlineExceeded) なども引き続き利用できます
+func WrapRequestError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrRequestTimeout, err)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%w: %w", ErrRequestCanceled, err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %w", ErrRequestTimeout, err)
+	}
+	return err
+}
@@ -1,7 +1,13 @@
 package utils
 
-import "fmt"
-
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ChatCompletion はChat Completions APIのレスポンスです
+// Function Calling と構造化出力のどちらのリクエストも同じ型を返すため、
+// GetFunctionCall と HandleResponse のどちらにも渡せます
 type ChatCompletion struct {
 	Choices           []Choice `json:"choices"`
 	Created           int64    `json:"created"`
@@ -10,22 +16,81 @@ type ChatCompletion struct {
 	Object            string   `json:"object"`
 	SystemFingerprint string   `json:"system_fingerprint"`
 	Usage             Usage    `json:"usage"`
+	// PromptFilterResults はAzure OpenAIのプロンプトに対するコンテンツフィルターの結果です
+	PromptFilterResults []PromptFilterResult `json:"prompt_filter_results,omitempty"`
 }
 
 type Choice struct {
-	FinishReason string      `json:"finish_reason"`
-	Index        int         `json:"index"`
-	LogProbs     any         `json:"logprobs"`
-	Message      ChatMessage `json:"message"` // Message を ChatMessage に変更
+	FinishReason string `json:"finish_reason"`
+	Index        int    `json:"index"`
+	// LogProbs は Parameters.LogProbs を指定した場合のトークンごとの対数確率です
+	LogProbs *LogProbs   `json:"logprobs"`
+	Message  ChatMessage `json:"message"`
+	// ContentFilterResults はAzure OpenAIの応答に対するコンテンツフィルターの結果です
+	ContentFilterResults *ContentFilterResults `json:"content_filter_results,omitempty"`
 }
 
-type ChatMessage struct { // Message を ChatMessage に変更
-	Content   any        `json:"content"`
-	Refusal   any        `json:"refusal"`
+type ChatMessage struct {
+	// Content は応答のテキストをJSONの文字列のまま保持します（テキストがない場合は null）
+	// テキストは Text で取り出せます
+	Content json.RawMessage `json:"content"`
+	// Refusal はモデルが回答を拒否した場合の理由です
+	Refusal   *string    `json:"refusal,omitempty"`
 	Role      string     `json:"role"`
 	ToolCalls []ToolCall `json:"tool_calls"`
 }
 
+// Text は応答のテキストを返します（テキストがない場合は空文字列）
+// Content がJSONの文字列でない場合は、そのままの内容を返します
+func (m ChatMessage) Text() string {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err != nil {
+		return string(m.Content)
+	}
+	return text
+}
+
+// ToMessage はレスポンスのChatMessageを次のリクエストに含められるMessageに変換します
+func (m ChatMessage) ToMessage() Message {
+	message := Message{
+		Role:      RoleAssistant,
+		ToolCalls: m.ToolCalls,
+	}
+	if m.Role != "" {
+		message.Role = Role(m.Role)
+	}
+	if len(m.Content) > 0 && string(m.Content) != "null" {
+		message.Content = m.Content
+	}
+	return message
+}
+
+// LogProbs はトークンごとの対数確率です
+type LogProbs struct {
+	Content []TokenLogProb `json:"content"`
+	Refusal []TokenLogProb `json:"refusal,omitempty"`
+}
+
+// TokenLogProb は生成されたトークンの対数確率と、同じ位置で確率の高かった候補です
+type TokenLogProb struct {
+	Token   string  `json:"token"`
+	LogProb float64 `json:"logprob"`
+	// Bytes はトークンのUTF-8のバイト列です（トークンが文字の途中で分かれる場合に使用します）
+	Bytes []int `json:"bytes"`
+	// TopLogProbs は Parameters.TopLogProbs を指定した場合の候補です
+	TopLogProbs []TopLogProb `json:"top_logprobs"`
+}
+
+// TopLogProb は候補のトークンと対数確率です
+type TopLogProb struct {
+	Token   string  `json:"token"`
+	LogProb float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
+}
+
 type ToolCall struct {
 	Function Function `json:"function"`
 	ID       string   `json:"id"`
@@ -45,6 +110,26 @@ type Usage struct {
 	TotalTokens             int                    `json:"total_tokens"`
 }
 
+// Add は2つのUsageを合算したUsageを返します
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		CompletionTokensDetails: CompletionTokenDetails{
+			AcceptedPredictionTokens: u.CompletionTokensDetails.AcceptedPredictionTokens + other.CompletionTokensDetails.AcceptedPredictionTokens,
+			AudioTokens:              u.CompletionTokensDetails.AudioTokens + other.CompletionTokensDetails.AudioTokens,
+			ReasoningTokens:          u.CompletionTokensDetails.ReasoningTokens + other.CompletionTokensDetails.ReasoningTokens,
+			RejectedPredictionTokens: u.CompletionTokensDetails.RejectedPredictionTokens + other.CompletionTokensDetails.RejectedPredictionTokens,
+		},
+		PromptTokens: u.PromptTokens + other.PromptTokens,
+		PromptTokensDetails: PromptTokenDetails{
+			AudioTokens:      u.PromptTokensDetails.AudioTokens + other.PromptTokensDetails.AudioTokens,
+			CachedTokens:     u.PromptTokensDetails.CachedTokens + other.PromptTokensDetails.CachedTokens,
+			CacheWriteTokens: u.PromptTokensDetails.CacheWriteTokens + other.PromptTokensDetails.CacheWriteTokens,
+		},
+		TotalTokens: u.TotalTokens + other.TotalTokens,
+	}
+}
+
 type CompletionTokenDetails struct {
 	AcceptedPredictionTokens int `json:"accepted_prediction_tokens"`
 	AudioTokens              int `json:"audio_tokens"`
@@ -55,6 +140,8 @@ type CompletionTokenDetails struct {
 type PromptTokenDetails struct {
 	AudioTokens  int `json:"audio_tokens"`
 	CachedTokens int `json:"cached_tokens"`
+	// CacheWriteTokens はプロンプトキャッシュに書き込まれた入力トークン数です（Anthropic のみ）
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
 }
 
 // GetFunctionCall はfunction名を指定してToolCallを取得します
@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultOllamaBaseURL はOllamaのOpenAI互換APIのベースURLです
+const DefaultOllamaBaseURL = "http://localhost:11434/v1"
+
+// StructuredOutputMode は構造化出力の要求方法です
+type StructuredOutputMode string
+
+const (
+	// StructuredOutputJSONSchema は response_format に json_schema を指定します
+	StructuredOutputJSONSchema StructuredOutputMode = "json_schema"
+	// StructuredOutputJSONObject は response_format に json_object を指定し、スキーマをシステムプロンプトで伝えます
+	StructuredOutputJSONObject StructuredOutputMode = "json_object"
+	// StructuredOutputPrompt は response_format を指定せず、スキーマをシステムプロンプトで伝えます
+	StructuredOutputPrompt StructuredOutputMode = "prompt"
+	// StructuredOutputAuto は json_schema から順に試し、サーバーが対応していない場合は次の方法に切り替えます
+	StructuredOutputAuto StructuredOutputMode = "auto"
+)
+
+// NewLocalClientConfig はOllama・llama.cpp server・vLLMなどのOpenAI互換サーバー向けのClientConfigを作成します
+// 認証は行わず、構造化出力はサーバーが対応している方法を自動で選択します
+func NewLocalClientConfig(baseURL string, model string) *ClientConfig {
+	if baseURL == "" {
+		baseURL = DefaultOllamaBaseURL
+	}
+	return &ClientConfig{
+		BaseURL:              baseURL,
+		Model:                model,
+		Client:               &http.Client{},
+		StructuredOutputMode: StructuredOutputAuto,
+	}
+}
+
+// Model は /models で取得できるモデルの情報です
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// ListModels はサーバーで利用できるモデルの一覧を取得します
+func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.endpoint("/models"), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, WrapRequestError(ctx, fmt.Errorf("error reading response: %w", err))
+	}
+
+	var list struct {
+		Data []Model `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	return list.Data, nil
+}
+
+// structuredModeCache は StructuredOutputAuto で確定した要求方法を保持します
+type structuredModeCache struct {
+	mu   sync.Mutex
+	mode StructuredOutputMode
+}
+
+// resolve は設定値に対して実際に使用する要求方法を返します
+func (m *structuredModeCache) resolve(configured StructuredOutputMode) StructuredOutputMode {
+	switch configured {
+	case "":
+		return StructuredOutputJSONSchema
+	case StructuredOutputAuto:
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.mode != "" {
+			return m.mode
+		}
+		return StructuredOutputJSONSchema
+	default:
+		return configured
+	}
+}
+
+func (m *structuredModeCache) store(mode StructuredOutputMode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mode = mode
+}
+
+// nextStructuredMode はフォールバック先の要求方法を返します
+func nextStructuredMode(mode StructuredOutputMode) (StructuredOutputMode, bool) {
+	switch mode {
+	case StructuredOutputJSONSchema:
+		return StructuredOutputJSONObject, true
+	case StructuredOutputJSONObject:
+		return StructuredOutputPrompt, true
+	default:
+		return "", false
+	}
+}
+
+// structuredOutput は構造化出力のリクエストを送信し、パースしたレスポンスを返します
+func (c *Client) structuredOutput(ctx context.Context, opts RequestOptions) (*APIResponse, error) {
+	configured := c.config.StructuredOutputMode
+	mode := c.structuredMode.resolve(configured)
+	for {
+		body, err := c.send(ctx, c.structuredOutputBody(opts, mode))
+		if err != nil {
+			if configured == StructuredOutputAuto && isUnsupportedFormatError(err) {
+				if next, ok := nextStructuredMode(mode); ok {
+					mode = next
+					continue
+				}
+			}
+			return nil, err
+		}
+		if configured == StructuredOutputAuto {
+			c.structuredMode.store(mode)
+		}
+
+		var apiResp APIResponse
+		if err := json.Unmarshal(body, &apiResp); err != nil {
+			return nil, fmt.Errorf("error parsing response: %v", err)
+		}
+		if mode != StructuredOutputJSONSchema {
+			cleanStructuredContent(&apiResp)
+		}
+		return &apiResp, nil
+	}
+}
+
+// isUnsupportedFormatError はサーバーが response_format に対応していないことを示すエラーかを判定します
+// 本文が response_format に言及し、かつ未対応・不明なパラメーターであることを示している場合に限ります
+// スキーマの誤りによるエラーで格下げし、その結果をキャッシュしてしまわないよう、スキーマ検証のエラーは除外します
+func isUnsupportedFormatError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusNotImplemented:
+	default:
+		return false
+	}
+
+	body := strings.ToLower(string(apiErr.Body))
+	if containsAny(body, "invalid schema", "invalid_json_schema", "schema validation") {
+		return false
+	}
+	if !containsAny(body, "response_format", "json_schema", "json_object") {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotImplemented ||
+		containsAny(body, "not supported", "unsupported", "unknown", "unrecognized", "not implemented", "extra inputs are not permitted")
+}
+
+// containsAny は s がいずれかの部分文字列を含むかを判定します
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaInstruction はスキーマをプロンプトで伝える際の指示文です
+const schemaInstruction = "Respond only with a JSON object that conforms to the following JSON Schema. Do not wrap it in markdown or add any other text.\n\n"
+
+// withSchemaInstruction はスキーマを含むシステムメッセージを先頭に追加したメッセージを返します
+func withSchemaInstruction(messages []Message, schemaJSON json.RawMessage) []Message {
+	// {"name": ..., "schema": {...}} の形式であれば schema 部分だけを使用する
+	var wrapper struct {
+		Schema json.RawMessage `json:"schema"`
+	}
+	schema := schemaJSON
+	if err := json.Unmarshal(schemaJSON, &wrapper); err == nil && len(wrapper.Schema) > 0 {
+		schema = wrapper.Schema
+	}
+
+	instruction := NewMessage(RoleSystem, schemaInstruction+string(schema))
+	return append([]Message{instruction}, messages...)
+}
+
+// cleanStructuredContent はレスポンスの内容からMarkdownのコードブロックを取り除きます
+func cleanStructuredContent(resp *APIResponse) {
+	for i := range resp.Choices {
+		var content string
+		if err := json.Unmarshal(resp.Choices[i].Message.Content, &content); err != nil {
+			continue
+		}
+		resp.Choices[i].Message.Content, _ = json.Marshal(stripCodeFence(content))
+	}
+}
+
+// stripPartialCodeFence は受信途中の内容から ```json ... ``` のコードブロックの記号を取り除きます
+// 開始行を受信し終えるまでは空文字列を返します
+func stripPartialCodeFence(s string) string {
+	s = strings.TrimLeft(s, " \t\r\n")
+	if !strings.HasPrefix(s, "`") {
+		return s
+	}
+	newline := strings.IndexByte(s, '\n')
+	if newline < 0 {
+		return ""
+	}
+	return strings.TrimRight(strings.TrimSpace(s[newline+1:]), "`")
+}
+
+// stripCodeFence は ```json ... ``` のようなMarkdownのコードブロックを取り除きます
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	if newline := strings.IndexByte(s, '\n'); newline >= 0 {
+		s = s[newline+1:]
+	} else {
+		s = strings.TrimPrefix(s, "```")
+	}
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(s, "```")
+	return strings.TrimSpace(s)
+}
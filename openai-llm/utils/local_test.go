@@ -0,0 +1,142 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// newLocalServer は json_schema に対応していないOpenAI互換サーバーを模したテスト用サーバーを作成します
+func newLocalServer(t *testing.T, formats *[]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"object":"list","data":[{"id":"llama3.2","object":"model","created":1,"owned_by":"library"}]}`)
+	})
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("expected no Authorization header, got %q", auth)
+		}
+		var body utils.RequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if body.Model != "llama3.2" {
+			t.Errorf("unexpected model: %s", body.Model)
+		}
+
+		format := "none"
+		if body.ResponseFormat != nil {
+			format = body.ResponseFormat.Type
+		}
+		*formats = append(*formats, format)
+		if format == "json_schema" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":{"message":"response_format type json_schema is not supported"}}`)
+			return
+		}
+
+		var system string
+		json.Unmarshal(body.Messages[0].Content, &system)
+		if body.Messages[0].Role != utils.RoleSystem || !strings.Contains(system, `"temperature"`) {
+			t.Errorf("expected schema to be embedded in a system message, got %+v", body.Messages[0])
+		}
+		io.WriteString(w, `{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"`+
+			"```json\\n{\\\"location\\\":\\\"Tokyo\\\",\\\"temperature\\\":19,\\\"unit\\\":\\\"C\\\",\\\"conditions\\\":\\\"Rain\\\"}\\n```"+`"}}]}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLocalStructuredOutputFallback(t *testing.T) {
+	var formats []string
+	server := newLocalServer(t, &formats)
+	client := utils.NewClient(utils.NewLocalClientConfig(server.URL+"/v1", "llama3.2"))
+
+	schemaJSON, _ := json.Marshal(schema.NewWeatherSchema())
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "What's the weather like in Tokyo today?")},
+		Schema:   schemaJSON,
+	}
+	for i := 0; i < 2; i++ {
+		res, err := client.SendRequestWithStructuredOutput(opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		weather, err := utils.HandleResponse[schema.WeatherResponse](res)
+		if err != nil {
+			t.Fatalf("unexpected error handling response: %v", err)
+		}
+		if weather.Location != "Tokyo" || weather.Conditions != "Rain" {
+			t.Errorf("unexpected weather: %+v", weather)
+		}
+	}
+
+	// 2回目以降はフォールバック先の方法を直接使用する
+	if got := strings.Join(formats, ","); got != "json_schema,json_object,json_object" {
+		t.Errorf("unexpected response_format sequence: %s", got)
+	}
+}
+
+func TestLocalStructuredOutputKeepsFormatOnOtherErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unrelated parameter", `{"error":{"message":"parameter logit_bias is not supported"}}`},
+		{"invalid schema", `{"error":{"message":"Invalid schema for response_format 'weather': In context=(), 'additionalProperties' is required to be supplied and to be false.","param":"response_format"}}`},
+		{"invalid json_schema name", `{"error":{"message":"response_format.json_schema.name must match the pattern ^[a-zA-Z0-9_-]+$","param":"response_format"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var formats []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body utils.RequestBody
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("failed to decode request: %v", err)
+				}
+				formats = append(formats, body.ResponseFormat.Type)
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, tt.body)
+			}))
+			t.Cleanup(server.Close)
+			client := utils.NewClient(utils.NewLocalClientConfig(server.URL+"/v1", "llama3.2"))
+
+			schemaJSON, _ := json.Marshal(schema.NewWeatherSchema())
+			opts := utils.RequestOptions{
+				Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "What's the weather like in Tokyo today?")},
+				Schema:   schemaJSON,
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := client.SendRequestWithStructuredOutput(opts); err == nil {
+					t.Fatal("expected the error to be returned")
+				}
+			}
+			// 格下げもキャッシュもされず、毎回 json_schema で送信する
+			if got := strings.Join(formats, ","); got != "json_schema,json_schema" {
+				t.Errorf("expected no fallback, got response_format sequence: %s", got)
+			}
+		})
+	}
+}
+
+func TestListModels(t *testing.T) {
+	var formats []string
+	server := newLocalServer(t, &formats)
+	client := utils.NewClient(utils.NewLocalClientConfig(server.URL+"/v1", "llama3.2"))
+
+	models, err := client.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(models) != 1 || models[0].ID != "llama3.2" || models[0].OwnedBy != "library" {
+		t.Errorf("unexpected models: %+v", models)
+	}
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/yuki5155/go-llms/openai-llm/models"
+)
+
+// ModelInfo はクライアントのモデルの情報をレジストリから返します
+func (c *Client) ModelInfo() (models.Model, bool) {
+	return c.models().Lookup(c.config.Model)
+}
+
+func (c *Client) models() *models.Registry {
+	if c.config.Models != nil {
+		return c.config.Models
+	}
+	return models.Default()
+}
+
+// checkModel はモデルがリクエストに必要な機能に対応しているかを確認します
+// レジストリに登録されていないモデル（ローカルのモデルやAzureのデプロイメントなど）は確認しません
+func (c *Client) checkModel(b RequestBody) error {
+	model, ok := c.models().Lookup(b.Model)
+	if !ok {
+		return nil
+	}
+	return model.Check(b.requirements())
+}
+
+// requirements はリクエストボディが必要とするモデルの機能を返します
+func (b *RequestBody) requirements() models.Requirements {
+	req := models.Requirements{
+		Vision:            hasImage(b.Messages),
+		Tools:             len(b.Tools) > 0,
+		StructuredOutputs: b.ResponseFormat != nil && b.ResponseFormat.Type == "json_schema",
+	}
+	if b.Parameters != nil {
+		req.ReasoningEffort = b.ReasoningEffort != ""
+		if b.MaxCompletionTokens != nil {
+			req.MaxOutputTokens = *b.MaxCompletionTokens
+		}
+	}
+	return req
+}
+
+// hasImage はメッセージに画像が含まれるかを判定します
+func hasImage(messages []Message) bool {
+	for _, m := range messages {
+		if !bytes.HasPrefix(bytes.TrimSpace(m.Content), []byte("[")) {
+			continue
+		}
+		var parts []Content
+		if err := json.Unmarshal(m.Content, &parts); err != nil {
+			continue
+		}
+		for _, part := range parts {
+			if part.Type == "image_url" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package utils_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/models"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+func TestUnsupportedFeatureRejectedLocally(t *testing.T) {
+	server, calls := newFlakyServer(t, 0, http.StatusOK, nil, "")
+
+	config := utils.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	config.Model = "gpt-3.5-turbo-0125"
+	client := utils.NewClient(config)
+
+	_, err := client.SendRequestWithFunctionCall(utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessageWithImage("https://example.com/cat.png", "What is this?")},
+	})
+	if !errors.Is(err, models.ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "image input") {
+		t.Errorf("expected error to mention image input, got %v", err)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected no request to be sent, got %d", calls.Load())
+	}
+
+	// テキストだけのメッセージは送信される
+	if _, err := client.SendRequestWithFunctionCall(utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", calls.Load())
+	}
+}
+
+func TestCustomRegistry(t *testing.T) {
+	server, _ := newFlakyServer(t, 0, http.StatusOK, nil, "")
+	config := utils.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	config.Model = "my-model"
+	config.Models = models.NewRegistry(models.Model{ID: "my-model", ContextWindow: 8192})
+	client := utils.NewClient(config)
+
+	info, ok := client.ModelInfo()
+	if !ok || info.ContextWindow != 8192 {
+		t.Fatalf("expected model info from custom registry, got %+v (found=%v)", info, ok)
+	}
+	_, err := client.SendRequestWithFunctionCall(utils.RequestOptions{
+		Messages:   []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+		Parameters: &utils.Parameters{ReasoningEffort: "low"},
+	})
+	if !errors.Is(err, models.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+
+	// 空のレジストリでは確認しない
+	config.Models = models.NewRegistry()
+	if _, err := utils.NewClient(config).SendRequestWithFunctionCall(utils.RequestOptions{
+		Messages:   []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+		Parameters: &utils.Parameters{ReasoningEffort: "low"},
+	}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
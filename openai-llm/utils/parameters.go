@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// Parameters はChat Completions APIの生成パラメーターです
+// nil や空の値は送信されず、APIの既定値が使用されます
+// ClientConfig.DefaultParameters と RequestOptions.Parameters の両方に指定した場合は、
+// リクエスト側で指定された項目だけがクライアントの既定値を上書きします
+type Parameters struct {
+	// Temperature はサンプリング温度です（0〜2）
+	Temperature *float64 `json:"temperature,omitempty"`
+	// TopP は核サンプリングの確率質量です（0〜1）
+	TopP *float64 `json:"top_p,omitempty"`
+	// MaxCompletionTokens は推論トークンを含む生成トークン数の上限です
+	MaxCompletionTokens *int `json:"max_completion_tokens,omitempty"`
+	// N は生成する選択肢の数です
+	N *int `json:"n,omitempty"`
+	// Stop は生成を停止する文字列です（最大4つ）
+	Stop []string `json:"stop,omitempty"`
+	// Seed は決定的なサンプリングのためのシード値です
+	Seed *int `json:"seed,omitempty"`
+	// PresencePenalty は既出のトークンに対するペナルティです（-2〜2）
+	PresencePenalty *float64 `json:"presence_penalty,omitempty"`
+	// FrequencyPenalty は出現頻度に応じたペナルティです（-2〜2）
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	// LogitBias はトークンIDごとの出現確率の補正値です（-100〜100）
+	LogitBias map[string]int `json:"logit_bias,omitempty"`
+	// LogProbs は生成したトークンの対数確率をレスポンスに含めるかです
+	LogProbs *bool `json:"logprobs,omitempty"`
+	// TopLogProbs は各位置で返す確率の高い候補の数です（0〜20、LogProbs が必要です）
+	TopLogProbs *int `json:"top_logprobs,omitempty"`
+	// User はエンドユーザーの識別子です
+	User string `json:"user,omitempty"`
+	// ToolChoice はツールの選択方法です（ツールを指定した場合のみ有効）
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+	// ParallelToolCalls はツールの並列呼び出しを許可するかです（ツールを指定した場合のみ有効）
+	ParallelToolCalls *bool `json:"parallel_tool_calls,omitempty"`
+	// ReasoningEffort は推論モデルの推論量です（none / minimal / low / medium / high）
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	// Store はレスポンスを保存して評価や蒸留に使用するかです
+	Store *bool `json:"store,omitempty"`
+	// Metadata は保存されたレスポンスに付与するキーと値です（最大16個）
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// ServiceTier は処理に使用するサービス階層です（auto / default / flex / priority / scale）
+	ServiceTier string `json:"service_tier,omitempty"`
+}
+
+// Ptr は値へのポインターを返します（Parameters の指定に使用します）
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Merge は p を基に、override で指定された項目を上書きしたParametersを返します
+func (p *Parameters) Merge(override *Parameters) *Parameters {
+	if p == nil && override == nil {
+		return nil
+	}
+	merged := &Parameters{}
+	if p != nil {
+		*merged = *p
+	}
+	if override == nil {
+		return merged
+	}
+
+	dst := reflect.ValueOf(merged).Elem()
+	src := reflect.ValueOf(override).Elem()
+	for i := range src.NumField() {
+		if field := src.Field(i); !field.IsZero() {
+			dst.Field(i).Set(field)
+		}
+	}
+	return merged
+}
+
+// clone はマップとスライス、ToolChoice を複製したParametersを返します
+func (p *Parameters) clone() *Parameters {
+	if p == nil {
+		return nil
+	}
+	params := *p
+	params.Stop = slices.Clone(p.Stop)
+	params.LogitBias = maps.Clone(p.LogitBias)
+	params.Metadata = maps.Clone(p.Metadata)
+	if p.ToolChoice != nil {
+		choice := *p.ToolChoice
+		params.ToolChoice = &choice
+	}
+	return &params
+}
+
+// WithoutToolDefaults はツールを指定しないリクエストのために、クライアントの既定値から来た
+// ツールに関する項目（ToolChoice と ParallelToolCalls）を除いたParametersを返します
+// override はリクエストで指定したParametersで、そこで指定した項目は残るため検証でエラーになります
+func (p *Parameters) WithoutToolDefaults(override *Parameters) *Parameters {
+	if p == nil {
+		return nil
+	}
+	params := *p
+	if override == nil || override.ToolChoice == nil {
+		params.ToolChoice = nil
+	}
+	if override == nil || override.ParallelToolCalls == nil {
+		params.ParallelToolCalls = nil
+	}
+	return &params
+}
+
+// Validate は明らかに不正な値をリクエストの送信前に検出します
+func (p *Parameters) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2, got %v", *p.Temperature)
+	}
+	if p.TopP != nil && (*p.TopP < 0 || *p.TopP > 1) {
+		return fmt.Errorf("top_p must be between 0 and 1, got %v", *p.TopP)
+	}
+	if p.MaxCompletionTokens != nil && *p.MaxCompletionTokens < 1 {
+		return fmt.Errorf("max_completion_tokens must be positive, got %d", *p.MaxCompletionTokens)
+	}
+	if p.N != nil && *p.N < 1 {
+		return fmt.Errorf("n must be positive, got %d", *p.N)
+	}
+	if len(p.Stop) > 4 {
+		return fmt.Errorf("at most 4 stop sequences are allowed, got %d", len(p.Stop))
+	}
+	if p.PresencePenalty != nil && (*p.PresencePenalty < -2 || *p.PresencePenalty > 2) {
+		return fmt.Errorf("presence_penalty must be between -2 and 2, got %v", *p.PresencePenalty)
+	}
+	if p.FrequencyPenalty != nil && (*p.FrequencyPenalty < -2 || *p.FrequencyPenalty > 2) {
+		return fmt.Errorf("frequency_penalty must be between -2 and 2, got %v", *p.FrequencyPenalty)
+	}
+	for token, bias := range p.LogitBias {
+		if bias < -100 || bias > 100 {
+			return fmt.Errorf("logit_bias for token %s must be between -100 and 100, got %d", token, bias)
+		}
+	}
+	if p.TopLogProbs != nil {
+		if *p.TopLogProbs < 0 || *p.TopLogProbs > 20 {
+			return fmt.Errorf("top_logprobs must be between 0 and 20, got %d", *p.TopLogProbs)
+		}
+		if p.LogProbs == nil || !*p.LogProbs {
+			return fmt.Errorf("top_logprobs requires logprobs")
+		}
+	}
+	if p.ToolChoice != nil {
+		if err := p.ToolChoice.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.ReasoningEffort != "" && !slices.Contains([]string{"none", "minimal", "low", "medium", "high"}, p.ReasoningEffort) {
+		return fmt.Errorf("unsupported reasoning_effort %q", p.ReasoningEffort)
+	}
+	if p.ServiceTier != "" && !slices.Contains([]string{"auto", "default", "flex", "priority", "scale"}, p.ServiceTier) {
+		return fmt.Errorf("unsupported service_tier %q", p.ServiceTier)
+	}
+	if len(p.Metadata) > 16 {
+		return fmt.Errorf("at most 16 metadata entries are allowed, got %d", len(p.Metadata))
+	}
+	for key, value := range p.Metadata {
+		if len(key) > 64 || len(value) > 512 {
+			return fmt.Errorf("metadata %q exceeds the maximum length (64 for keys, 512 for values)", key)
+		}
+	}
+	return nil
+}
+
+// validate はリクエスト全体としての組み合わせを検証します
+func (b *RequestBody) validate() error {
+	if err := b.Parameters.Validate(); err != nil {
+		return err
+	}
+	if b.Parameters == nil {
+		return nil
+	}
+	if len(b.Tools) == 0 && (b.ToolChoice != nil || b.ParallelToolCalls != nil) {
+		return fmt.Errorf("tool_choice and parallel_tool_calls require tools")
+	}
+	if b.ToolChoice != nil {
+		names, err := toolNames(b.Tools)
+		if err != nil {
+			return err
+		}
+		return b.ToolChoice.ValidateTools(names)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// newCaptureServer はリクエストボディを記録して固定のレスポンスを返すテスト用サーバーを作成します
+func newCaptureServer(t *testing.T, response string) (*httptest.Server, *map[string]any) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, &captured
+}
+
+func TestParametersSerialization(t *testing.T) {
+	server, captured := newCaptureServer(t, testCompletionBody)
+	config := utils.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	// reasoning_effort に対応しているモデルを使用する
+	config.Model = "o4-mini"
+	config.DefaultParameters = &utils.Parameters{
+		Temperature: utils.Ptr(0.2),
+		Seed:        utils.Ptr(42),
+		User:        "default-user",
+	}
+	client := utils.NewClient(config)
+
+	toolsJSON, _ := json.Marshal([]schema.Tool{*schema.NewWeatherFunctionCallSchema()})
+	_, err := client.SendRequestWithFunctionCall(utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+		Schema:   toolsJSON,
+		Parameters: &utils.Parameters{
+			Temperature:         utils.Ptr(0.0),
+			MaxCompletionTokens: utils.Ptr(256),
+			Stop:                []string{"END"},
+			LogitBias:           map[string]int{"50256": -100},
+			ToolChoice:          &utils.ToolChoice{Function: "weather"},
+			ParallelToolCalls:   utils.Ptr(false),
+			ReasoningEffort:     "low",
+			Store:               utils.Ptr(true),
+			Metadata:            map[string]string{"pipeline": "test"},
+			ServiceTier:         "flex",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := *captured
+	// リクエスト側の 0 はクライアントの既定値を上書きする
+	if body["temperature"] != float64(0) {
+		t.Errorf("expected request temperature to override default, got %v", body["temperature"])
+	}
+	if body["seed"] != float64(42) || body["user"] != "default-user" {
+		t.Errorf("expected client defaults to be kept, got seed=%v user=%v", body["seed"], body["user"])
+	}
+	if body["max_completion_tokens"] != float64(256) || body["parallel_tool_calls"] != false || body["store"] != true {
+		t.Errorf("unexpected parameters: %v", body)
+	}
+	if body["reasoning_effort"] != "low" || body["service_tier"] != "flex" {
+		t.Errorf("unexpected string parameters: %v", body)
+	}
+	choice, ok := body["tool_choice"].(map[string]any)
+	if !ok || choice["type"] != "function" || choice["function"].(map[string]any)["name"] != "weather" {
+		t.Errorf("unexpected tool_choice: %v", body["tool_choice"])
+	}
+	for _, key := range []string{"top_p", "n", "presence_penalty", "frequency_penalty"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("expected unset %s to be omitted", key)
+		}
+	}
+}
+
+func TestToolDefaultsDroppedWithoutTools(t *testing.T) {
+	server, captured := newCaptureServer(t, testCompletionBody)
+	config := utils.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	config.DefaultParameters = &utils.Parameters{
+		ToolChoice:        utils.ToolChoiceRequired(),
+		ParallelToolCalls: utils.Ptr(false),
+	}
+	client := utils.NewClient(config)
+
+	_, err := client.SendRequestWithStructuredOutput(utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+		Schema:   []byte(`{"name":"weather","schema":{"type":"object"}}`),
+	})
+	if err != nil {
+		t.Fatalf("expected tool-only defaults to be dropped, got %v", err)
+	}
+	for _, key := range []string{"tool_choice", "parallel_tool_calls"} {
+		if _, ok := (*captured)[key]; ok {
+			t.Errorf("expected %s to be omitted without tools", key)
+		}
+	}
+
+	// リクエストで指定した場合はツールがなければエラーになる
+	_, err = client.SendRequestWithFunctionCall(utils.RequestOptions{
+		Messages:   []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+		Parameters: &utils.Parameters{ToolChoice: utils.ToolChoiceRequired()},
+	})
+	if err == nil || !strings.Contains(err.Error(), "require tools") {
+		t.Errorf("expected error for request tool_choice without tools, got %v", err)
+	}
+}
+
+func TestParametersValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters *utils.Parameters
+		tools      bool
+		want       string
+	}{
+		{"temperature", &utils.Parameters{Temperature: utils.Ptr(2.5)}, false, "temperature"},
+		{"top_p", &utils.Parameters{TopP: utils.Ptr(-0.1)}, false, "top_p"},
+		{"max tokens", &utils.Parameters{MaxCompletionTokens: utils.Ptr(0)}, false, "max_completion_tokens"},
+		{"stop", &utils.Parameters{Stop: []string{"a", "b", "c", "d", "e"}}, false, "stop"},
+		{"logit bias", &utils.Parameters{LogitBias: map[string]int{"1": 101}}, false, "logit_bias"},
+		{"reasoning effort", &utils.Parameters{ReasoningEffort: "extreme"}, false, "reasoning_effort"},
+		{"top logprobs range", &utils.Parameters{LogProbs: utils.Ptr(true), TopLogProbs: utils.Ptr(21)}, false, "top_logprobs"},
+		{"top logprobs without logprobs", &utils.Parameters{TopLogProbs: utils.Ptr(3)}, false, "requires logprobs"},
+		{"tool choice mode", &utils.Parameters{ToolChoice: &utils.ToolChoice{Mode: "always"}}, true, "tool_choice"},
+		{"tool choice without tools", &utils.Parameters{ToolChoice: &utils.ToolChoice{Mode: "required"}}, false, "require tools"},
+		{"parallel without tools", &utils.Parameters{ParallelToolCalls: utils.Ptr(true)}, false, "require tools"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("invalid request should not be sent")
+	}))
+	t.Cleanup(server.Close)
+	client := newTestClient(server)
+	toolsJSON, _ := json.Marshal([]schema.Tool{*schema.NewWeatherFunctionCallSchema()})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := utils.RequestOptions{
+				Messages:   []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+				Parameters: tt.parameters,
+			}
+			if tt.tools {
+				opts.Schema = toolsJSON
+			}
+			_, err := client.SendRequestWithFunctionCall(opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParametersMerge(t *testing.T) {
+	var base *utils.Parameters
+	if base.Merge(nil) != nil {
+		t.Error("expected nil when both are nil")
+	}
+
+	defaults := &utils.Parameters{Temperature: utils.Ptr(1.0), Stop: []string{"x"}}
+	merged := defaults.Merge(&utils.Parameters{TopP: utils.Ptr(0.5)})
+	if *merged.Temperature != 1.0 || *merged.TopP != 0.5 || len(merged.Stop) != 1 {
+		t.Errorf("unexpected merge result: %+v", merged)
+	}
+	if defaults.TopP != nil {
+		t.Error("merge must not modify the defaults")
+	}
+
+	var choice utils.ToolChoice
+	if err := json.Unmarshal([]byte(`{"type":"function","function":{"name":"weather"}}`), &choice); err != nil || choice.Function != "weather" {
+		t.Errorf("unexpected tool choice: %+v, %v", choice, err)
+	}
+}
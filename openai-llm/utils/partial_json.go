@@ -0,0 +1,147 @@
+package utils
+
+import "strings"
+
+// partialFrame は部分JSONを走査する際のオブジェクト・配列のネスト状態です
+type partialFrame struct {
+	array     bool
+	expectKey bool
+	// locked は配列要素の内部であることを示します
+	// 配列要素は完成するまでスナップショットに含めません
+	locked bool
+}
+
+// completePartialJSON は途中までのJSONを、完成している値だけを残した有効なJSONに補完します
+// 未完成の文字列・数値・リテラルは取り除かれ、配列の要素は閉じられた時点で初めて含まれます
+// 有効なJSONを組み立てられない場合は false を返します
+func completePartialJSON(data string) (string, bool) {
+	var stack []partialFrame
+	cut := -1
+	var closers string
+
+	top := func() *partialFrame {
+		if len(stack) == 0 {
+			return nil
+		}
+		return &stack[len(stack)-1]
+	}
+	recordCut := func(pos int) {
+		if frame := top(); frame != nil && frame.locked {
+			return
+		}
+		cut = pos
+		closers = closingBrackets(stack)
+	}
+
+	i := 0
+scan:
+	for i < len(data) {
+		switch c := data[i]; c {
+		case ' ', '\t', '\n', '\r':
+			i++
+
+		case '{', '[':
+			locked := false
+			if frame := top(); frame != nil {
+				locked = frame.locked || frame.array
+			}
+			stack = append(stack, partialFrame{array: c == '[', expectKey: c == '{', locked: locked})
+			i++
+			recordCut(i)
+
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1].array != (c == ']') {
+				return "", false
+			}
+			stack = stack[:len(stack)-1]
+			i++
+			recordCut(i)
+
+		case ',':
+			if frame := top(); frame != nil && !frame.array {
+				frame.expectKey = true
+			}
+			i++
+
+		case ':':
+			i++
+
+		case '"':
+			end, ok := scanString(data, i)
+			if !ok {
+				break scan
+			}
+			i = end
+			if frame := top(); frame != nil && !frame.array && frame.expectKey {
+				frame.expectKey = false
+				continue
+			}
+			recordCut(i)
+
+		case 't', 'f', 'n':
+			literal := map[byte]string{'t': "true", 'f': "false", 'n': "null"}[c]
+			rest := data[i:]
+			if len(rest) < len(literal) {
+				if !strings.HasPrefix(literal, rest) {
+					return "", false
+				}
+				break scan
+			}
+			if !strings.HasPrefix(rest, literal) {
+				return "", false
+			}
+			i += len(literal)
+			recordCut(i)
+
+		default:
+			if !isNumberChar(c) {
+				return "", false
+			}
+			end := i
+			for end < len(data) && isNumberChar(data[end]) {
+				end++
+			}
+			// 末尾の数値は続きが届く可能性があるため未完成として扱う
+			if end == len(data) {
+				break scan
+			}
+			i = end
+			recordCut(i)
+		}
+	}
+
+	if cut < 0 {
+		return "", false
+	}
+	return data[:cut] + closers, true
+}
+
+// scanString は start の位置から始まる文字列の終端（閉じ引用符の次）を返します
+func scanString(data string, start int) (int, bool) {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+func isNumberChar(c byte) bool {
+	return (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+}
+
+// closingBrackets は開いているオブジェクト・配列を閉じる文字列を返します
+func closingBrackets(stack []partialFrame) string {
+	var b strings.Builder
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].array {
+			b.WriteByte(']')
+		} else {
+			b.WriteByte('}')
+		}
+	}
+	return b.String()
+}
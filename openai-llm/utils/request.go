@@ -2,16 +2,23 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+
+	"github.com/yuki5155/go-llms/openai-llm/models"
 )
 
 const (
 	DefaultAPIEndpoint = "https://api.openai.com/v1/chat/completions"
-	DefaultModel       = "gpt-4o-2024-08-06"
+	DefaultModel       = models.DefaultModel
+
+	chatCompletionsPath = "/chat/completions"
 )
 
 type Role string
@@ -20,6 +27,7 @@ const (
 	RoleSystem    Role = "system"
 	RoleUser      Role = "user"
 	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
 )
 
 type ImageUrl struct {
@@ -35,11 +43,15 @@ type Content struct {
 type Message struct {
 	Role    Role            `json:"role"`
 	Content json.RawMessage `json:"content"`
+	// ToolCalls はアシスタントが要求したツール呼び出しです（RoleAssistantのみ）
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID は結果を返すツール呼び出しのIDです（RoleToolのみ）
+	ToolCallID string `json:"tool_call_id,omitempty"`
 }
 
 type RequestFormat struct {
 	Type       string          `json:"type"`
-	JSONSchema json.RawMessage `json:"json_schema"`
+	JSONSchema json.RawMessage `json:"json_schema,omitempty"`
 }
 
 type RequestBody struct {
@@ -47,13 +59,39 @@ type RequestBody struct {
 	Messages       []Message       `json:"messages"`
 	ResponseFormat *RequestFormat  `json:"response_format,omitempty"`
 	Tools          json.RawMessage `json:"tools,omitempty"`
+	Stream         bool            `json:"stream,omitempty"`
+	StreamOptions  *StreamOptions  `json:"stream_options,omitempty"`
+	// Parameters の各フィールドはリクエストボディのトップレベルに展開されます
+	*Parameters
+}
+
+// StreamOptions はストリーミング時のオプションを定義します
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type ClientConfig struct {
+	// APIKey は Authorization ヘッダーに使用します（空の場合は送信しません）
 	APIKey   string
 	Endpoint string
-	Model    string
-	Client   *http.Client
+	// BaseURL は http://localhost:11434/v1 のようなAPIのベースURLです
+	// 指定した場合は Endpoint より優先され、各APIのURLはこれを基に組み立てられます
+	BaseURL string
+	Model   string
+	Client  *http.Client
+	// Retry は失敗時の再試行方針です（nilの場合は再試行しません）
+	Retry *RetryPolicy
+	// StructuredOutputMode は構造化出力の要求方法です（空の場合は json_schema）
+	StructuredOutputMode StructuredOutputMode
+	// Azure を指定した場合はAzure OpenAIのデプロイメントに接続します
+	Azure *AzureConfig
+	// DefaultParameters は全てのリクエストに適用する生成パラメーターです
+	DefaultParameters *Parameters
+	// CostSettings はコストを集計する CostTracker とクライアントの名前です
+	CostSettings
+	// Models はモデルの機能を確認するためのレジストリです（nilの場合は models.Default()）
+	// 登録されていないモデルへのリクエストは確認せずに送信されます
+	Models *models.Registry
 }
 
 func NewClientConfig(apiKey string) *ClientConfig {
@@ -67,6 +105,8 @@ func NewClientConfig(apiKey string) *ClientConfig {
 
 type Client struct {
 	config *ClientConfig
+	// structuredMode は StructuredOutputAuto で確定した要求方法です
+	structuredMode structuredModeCache
 }
 
 func NewClient(config *ClientConfig) *Client {
@@ -76,6 +116,8 @@ func NewClient(config *ClientConfig) *Client {
 type RequestOptions struct {
 	Messages []Message
 	Schema   json.RawMessage
+	// Parameters はこのリクエストの生成パラメーターです（ClientConfig.DefaultParameters を上書きします）
+	Parameters *Parameters
 }
 
 func NewMessage(role Role, content string) Message {
@@ -93,6 +135,13 @@ func NewMessage(role Role, content string) Message {
 	}
 }
 
+// NewToolMessage はツール呼び出しの結果を返すメッセージを作成します
+func NewToolMessage(toolCallID string, content string) Message {
+	message := NewMessage(RoleTool, content)
+	message.ToolCallID = toolCallID
+	return message
+}
+
 func NewMessageWithImage(imageUrl string, text string) Message {
 	imageContent := Content{
 		Type: "image_url",
@@ -134,106 +183,197 @@ func NewMessageWithImageBase64(imageBytes []byte, text string) Message {
 	}
 }
 
+// SendRequestWithFunctionCall はFunction Callingのリクエストを送信します
 func (c *Client) SendRequestWithFunctionCall(opts RequestOptions) (*ChatCompletion, error) {
+	return c.SendRequestWithFunctionCallContext(context.Background(), opts)
+}
+
+// SendRequestWithFunctionCallContext はコンテキストを指定してFunction Callingのリクエストを送信します
+// コンテキストのキャンセルや期限はHTTPリクエストまで伝播されます
+func (c *Client) SendRequestWithFunctionCallContext(ctx context.Context, opts RequestOptions) (*ChatCompletion, error) {
+	if len(opts.Messages) == 0 {
+		return nil, fmt.Errorf("at least one message is required")
+	}
+
+	body, err := c.send(ctx, c.functionCallBody(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	var completion *ChatCompletion
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	return completion, nil
+}
+
+// SendRequestWithStructuredOutput は構造化出力のリクエストを送信します
+func (c *Client) SendRequestWithStructuredOutput(opts RequestOptions) (*APIResponse, error) {
+	return c.SendRequestWithStructuredOutputContext(context.Background(), opts)
+}
+
+// SendRequestWithStructuredOutputContext はコンテキストを指定して構造化出力のリクエストを送信します
+// コンテキストのキャンセルや期限はHTTPリクエストまで伝播されます
+func (c *Client) SendRequestWithStructuredOutputContext(ctx context.Context, opts RequestOptions) (*APIResponse, error) {
 	if len(opts.Messages) == 0 {
 		return nil, fmt.Errorf("at least one message is required")
 	}
 
-	reqBody := RequestBody{
+	apiResp, err := c.structuredOutput(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiResp, nil
+}
+
+// functionCallBody はFunction Calling用のリクエストボディを作成します
+func (c *Client) functionCallBody(opts RequestOptions) RequestBody {
+	params := c.config.DefaultParameters.Merge(opts.Parameters)
+	if len(opts.Schema) == 0 {
+		params = params.WithoutToolDefaults(opts.Parameters)
+	}
+	return RequestBody{
 		Model:          c.config.Model,
 		Messages:       opts.Messages,
 		Tools:          opts.Schema,
 		ResponseFormat: nil,
+		Parameters:     params,
 	}
+}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("error marshalling request: %v", err)
+// structuredOutputBody は構造化出力用のリクエストボディを作成します
+func (c *Client) structuredOutputBody(opts RequestOptions, mode StructuredOutputMode) RequestBody {
+	body := RequestBody{
+		Model:      c.config.Model,
+		Parameters: c.config.DefaultParameters.Merge(opts.Parameters).WithoutToolDefaults(opts.Parameters),
 	}
-	req, err := http.NewRequest("POST", c.config.Endpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
+	switch mode {
+	case StructuredOutputJSONObject:
+		body.Messages = withSchemaInstruction(opts.Messages, opts.Schema)
+		body.ResponseFormat = &RequestFormat{Type: "json_object"}
+	case StructuredOutputPrompt:
+		body.Messages = withSchemaInstruction(opts.Messages, opts.Schema)
+	default:
+		body.Messages = opts.Messages
+		body.ResponseFormat = &RequestFormat{
+			Type:       "json_schema",
+			JSONSchema: opts.Schema,
+		}
 	}
+	return body
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
-
-	resp, err := c.config.Client.Do(req)
+// send はリクエストボディを送信し、レスポンスボディを返します
+func (c *Client) send(ctx context.Context, reqBody RequestBody) ([]byte, error) {
+	resp, err := c.post(ctx, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned non-200 status code: %d, body: %s", resp.StatusCode, string(body))
-	}
-
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
-	}
-	var parsedJson map[string]interface{}
-	if err := json.Unmarshal(body, &parsedJson); err != nil {
-		return nil, fmt.Errorf("error unmarshaling JSON: %v", err)
-	}
-
-	var completion *ChatCompletion
-	if err := json.Unmarshal(body, &completion); err != nil {
-		return nil, fmt.Errorf("error parsing response: %v", err)
+		return nil, WrapRequestError(ctx, fmt.Errorf("error reading response: %w", err))
 	}
+	c.recordCost(ctx, body)
 
-	return completion, nil
-
+	return body, nil
 }
 
-func (c *Client) SendRequestWithStructuredOutput(opts RequestOptions) (*APIResponse, error) {
-	if len(opts.Messages) == 0 {
-		return nil, fmt.Errorf("at least one message is required")
+// post はリクエストボディをChat Completions APIに送信します
+// 呼び出し側でレスポンスボディを閉じる必要があります
+func (c *Client) post(ctx context.Context, reqBody RequestBody) (*http.Response, error) {
+	if err := reqBody.validate(); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
 	}
-
-	reqBody := RequestBody{
-		Model:    c.config.Model,
-		Messages: opts.Messages,
-		ResponseFormat: &RequestFormat{
-			Type:       "json_schema",
-			JSONSchema: opts.Schema,
-		},
+	if err := c.checkModel(reqBody); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if err := c.config.CostSettings.Allow(ctx, c.config.Model); err != nil {
+		return nil, err
 	}
-
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("error marshalling request: %v", err)
 	}
+	return c.do(ctx, http.MethodPost, c.endpoint(chatCompletionsPath), jsonData)
+}
 
-	req, err := http.NewRequest("POST", c.config.Endpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
+// endpoint はAPIのパスに対応するURLを返します
+// BaseURL が未指定の場合は Endpoint からベースURLを求めます
+func (c *Client) endpoint(path string) string {
+	if c.config.Azure != nil {
+		return c.azureEndpoint(path)
+	}
+	if c.config.BaseURL != "" {
+		return strings.TrimRight(c.config.BaseURL, "/") + path
+	}
+	if path == chatCompletionsPath {
+		return c.config.Endpoint
 	}
+	return strings.TrimSuffix(c.config.Endpoint, chatCompletionsPath) + path
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+// do はリトライポリシーに従ってリクエストを送信し、ステータスコード200のレスポンスを返します
+// 呼び出し側でレスポンスボディを閉じる必要があります
+func (c *Client) do(ctx context.Context, method, url string, jsonData []byte) (*http.Response, error) {
+	policy := c.config.Retry
+	for attempt := 1; ; attempt++ {
+		resp, err := c.doOnce(ctx, method, url, jsonData)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= policy.maxAttempts() || !isRetryable(ctx, err) {
+			return nil, err
+		}
+
+		wait := policy.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			if d, ok := apiErr.RetryAfter(); ok {
+				// サーバーの指定より早く再試行するとレート制限が続くため、待てない場合は諦める
+				if !policy.allows(d) {
+					return nil, err
+				}
+				wait = d
+			}
+		}
+		if sleepErr := sleepContext(ctx, wait); sleepErr != nil {
+			return nil, WrapRequestError(ctx, fmt.Errorf("error waiting to retry: %w", err))
+		}
+	}
+}
 
-	resp, err := c.config.Client.Do(req)
+// doOnce はリクエストを1回だけ送信します
+func (c *Client) doOnce(ctx context.Context, method, url string, jsonData []byte) (*http.Response, error) {
+	var body io.Reader
+	if jsonData != nil {
+		body = bytes.NewReader(jsonData)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
+		return nil, fmt.Errorf("error creating request: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned non-200 status code: %d, body: %s", resp.StatusCode, string(body))
+	if jsonData != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err := c.authorize(ctx, req); err != nil {
+		return nil, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := c.config.Client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
+		return nil, WrapRequestError(ctx, fmt.Errorf("error sending request: %w", err))
 	}
 
-	var apiResp APIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("error parsing response: %v", err)
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, NewAPIError(resp, body)
 	}
 
-	return &apiResp, nil
+	return resp, nil
 }
@@ -1,8 +1,13 @@
 package utils_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/yuki5155/go-llms/openai-llm/utils"
 )
@@ -24,3 +29,68 @@ func TestContext(t *testing.T) {
 		t.Errorf("JSON output is incorrect")
 	}
 }
+
+// newTestClient はテスト用サーバーに向けたクライアントを作成します
+func newTestClient(server *httptest.Server) *utils.Client {
+	config := utils.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	return utils.NewClient(config)
+}
+
+// newBlockingServer はリクエストを受け取ったまま応答しないテスト用サーバーを作成します
+func newBlockingServer(t *testing.T, started chan<- struct{}) *httptest.Server {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if started != nil {
+			close(started)
+		}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return server
+}
+
+func TestSendRequestContextTimeout(t *testing.T) {
+	server := newBlockingServer(t, nil)
+
+	client := newTestClient(server)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	}
+	_, err := client.SendRequestWithFunctionCallContext(ctx, opts)
+	if !errors.Is(err, utils.ErrRequestTimeout) {
+		t.Fatalf("expected ErrRequestTimeout, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to wrap context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestSendRequestContextCanceled(t *testing.T) {
+	started := make(chan struct{})
+	server := newBlockingServer(t, started)
+
+	client := newTestClient(server)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	}
+	_, err := client.SendRequestWithStructuredOutputContext(ctx, opts)
+	if !errors.Is(err, utils.ErrRequestCanceled) {
+		t.Fatalf("expected ErrRequestCanceled, got %v", err)
+	}
+}
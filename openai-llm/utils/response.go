@@ -2,21 +2,19 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
 )
 
-type ResponseChoice struct {
-	Message struct {
-		Role    string          `json:"role"`
-		Content json.RawMessage `json:"content"`
-		Refusal *string         `json:"refusal,omitempty"`
-	} `json:"message"`
-	FinishReason string `json:"finish_reason"`
-}
+// APIResponse は構造化出力のレスポンスです
+// Function Calling のレスポンスと同じ ChatCompletion の別名です
+type APIResponse = ChatCompletion
 
-type APIResponse struct {
-	Choices []ResponseChoice `json:"choices"`
-}
+// ResponseChoice は構造化出力のレスポンスの選択肢です（Choice の別名です）
+type ResponseChoice = Choice
 
 func ParseStructuredResponse[T any](content json.RawMessage) (*T, error) {
 	// 最初のJSONアンマーシャル：文字列として取得
@@ -37,25 +35,99 @@ func ParseStructuredResponse[T any](content json.RawMessage) (*T, error) {
 	return &result, nil
 }
 
-// カスタムエラーにディレクトリに移行する
+// HandleResponse が返す ResponseError がラップするエラーです
+// errors.Is(err, ErrContentFilter) のように原因を判別できます
+var (
+	ErrNullResponse           = errors.New("response is nil")
+	ErrNoChoices              = errors.New("no choices in the API response")
+	ErrModelRefusal           = errors.New("model refused the request")
+	ErrEmptyContent           = errors.New("response content is empty")
+	ErrParse                  = errors.New("error parsing response")
+	ErrTokenLimit             = errors.New("response truncated due to token limit")
+	ErrContentFilter          = errors.New("response filtered due to content restrictions")
+	ErrUnexpectedFinishReason = errors.New("unexpected finish reason")
+	ErrSchemaViolation        = errors.New("response does not match the schema")
+)
+
+// responseErrorSentinels は ResponseError.Type に対応するエラーです
+var responseErrorSentinels = map[string]error{
+	"NullResponse":           ErrNullResponse,
+	"NoChoices":              ErrNoChoices,
+	"ModelRefusal":           ErrModelRefusal,
+	"EmptyContent":           ErrEmptyContent,
+	"ParseError":             ErrParse,
+	"TokenLimit":             ErrTokenLimit,
+	"ContentFilter":          ErrContentFilter,
+	"UnexpectedFinishReason": ErrUnexpectedFinishReason,
+	"SchemaViolation":        ErrSchemaViolation,
+}
+
+// ResponseError はレスポンスを結果として扱えなかったことを表します
 type ResponseError struct {
 	Type    string
 	Message string
+	// Err は Type に対応するエラーです（errors.Is で判定できます）
+	Err error
 }
 
 func (e *ResponseError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Type, e.Message)
 }
 
-// [TODO]NewResponseError は新しいResponseErrorを作成します
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// NewResponseError は新しいResponseErrorを作成します
+// Type が既知の値であれば、対応するエラーをラップします
 func NewResponseError(errorType, message string) *ResponseError {
 	return &ResponseError{
 		Type:    errorType,
 		Message: message,
+		Err:     responseErrorSentinels[errorType],
+	}
+}
+
+// SchemaViolation はレスポンスがスキーマに従っていないことを表します
+// HandleResponse が返す ResponseError からは errors.As で取り出せます
+type SchemaViolation struct {
+	Violations []schema.Violation
+}
+
+func (e *SchemaViolation) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.String())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *SchemaViolation) Unwrap() error {
+	return ErrSchemaViolation
+}
+
+// HandleOption は HandleResponse の動作を変更するオプションです
+type HandleOption func(*handleOptions)
+
+type handleOptions struct {
+	schema *schema.BaseSchema
+}
+
+// WithSchemaValidation はパースの前にレスポンスをスキーマで検証します
+// 必須フィールド、型、enum、const、additionalProperties、文字列・数値・配列の制約を、$ref や anyOf を解決しながら検証します
+func WithSchemaValidation(s schema.BaseSchema) HandleOption {
+	return func(o *handleOptions) {
+		o.schema = &s
 	}
 }
 
-func HandleResponse[T any](resp *APIResponse) (*T, error) {
+// HandleResponse はレスポンスの終了理由を確認し、構造化出力を型 T にパースします
+func HandleResponse[T any](resp *APIResponse, opts ...HandleOption) (*T, error) {
+	var options handleOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	if resp == nil {
 		return nil, NewResponseError("NullResponse", "response is nil")
 	}
@@ -76,6 +148,12 @@ func HandleResponse[T any](resp *APIResponse) (*T, error) {
 			return nil, NewResponseError("EmptyContent", "response content is empty")
 		}
 
+		if options.schema != nil {
+			if err := validateContent(*options.schema, choice.Message.Content); err != nil {
+				return nil, err
+			}
+		}
+
 		// 構造化レスポンスのパース
 		result, err := ParseStructuredResponse[T](choice.Message.Content)
 		if err != nil {
@@ -87,14 +165,43 @@ func HandleResponse[T any](resp *APIResponse) (*T, error) {
 	case "length":
 		return nil, NewResponseError("TokenLimit", "the response was truncated due to token limit")
 	case "content_filter":
-		return nil, NewResponseError("ContentFilter", "the response was filtered due to content restrictions")
+		message := "the response was filtered due to content restrictions"
+		if categories := choice.ContentFilterResults.FilteredCategories(); len(categories) > 0 {
+			message += fmt.Sprintf(" (%s)", strings.Join(categories, ", "))
+		}
+		return nil, NewResponseError("ContentFilter", message)
 	default:
 		return nil, NewResponseError("UnexpectedFinishReason", fmt.Sprintf("unexpected finish reason: %s", choice.FinishReason))
 	}
 }
 
+// validateContent は文字列としてエンコードされたJSONの内容をスキーマで検証します
+func validateContent(s schema.BaseSchema, content json.RawMessage) error {
+	var jsonString string
+	if err := json.Unmarshal(content, &jsonString); err != nil {
+		return NewResponseError("ParseError", fmt.Sprintf("error parsing response: error unmarshaling outer JSON: %v", err))
+	}
+	violations, err := schema.ValidateJSON(s, []byte(jsonString))
+	if err != nil {
+		return NewResponseError("ParseError", fmt.Sprintf("error parsing response: error unmarshaling inner JSON: %v", err))
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	violation := &SchemaViolation{Violations: violations}
+	return &ResponseError{
+		Type:    "SchemaViolation",
+		Message: violation.Error(),
+		Err:     violation,
+	}
+}
+
+// ResponseErrorIs はエラーが指定した Type の ResponseError を含むかを判定します
+//
+// Deprecated: errors.Is(err, ErrContentFilter) のように番兵エラーで判定してください
 func ResponseErrorIs(err error, errorType string) bool {
-	if respErr, ok := err.(*ResponseError); ok {
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
 		return respErr.Type == errorType
 	}
 	return false
@@ -0,0 +1,109 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+func TestHandleResponseSchemaValidation(t *testing.T) {
+	newResponse := func(content string) *utils.APIResponse {
+		var choice utils.ResponseChoice
+		choice.FinishReason = "stop"
+		choice.Message.Content, _ = json.Marshal(content)
+		return &utils.APIResponse{Choices: []utils.ResponseChoice{choice}}
+	}
+	weatherSchema := schema.NewWeatherSchema().Schema
+
+	valid := newResponse(`{"location":"Tokyo","temperature":22,"unit":"C","conditions":"Clear"}`)
+	if _, err := utils.HandleResponse[schema.WeatherResponse](valid, utils.WithSchemaValidation(weatherSchema)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	invalid := newResponse(`{"location":"Tokyo","temperature":"warm","unit":"K","extra":true}`)
+	_, err := utils.HandleResponse[schema.WeatherResponse](invalid, utils.WithSchemaValidation(weatherSchema))
+	if !errors.Is(err, utils.ErrSchemaViolation) {
+		t.Fatalf("expected ErrSchemaViolation, got %v", err)
+	}
+	var violation *utils.SchemaViolation
+	if !errors.As(err, &violation) {
+		t.Fatalf("expected *SchemaViolation, got %T", err)
+	}
+
+	got := make(map[string]string)
+	for _, v := range violation.Violations {
+		got[v.Path] = v.Keyword
+	}
+	want := map[string]string{
+		"$.conditions":  "required",
+		"$.temperature": "type",
+		"$.unit":        "enum",
+		"$.extra":       "additionalProperties",
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %v, got %v", want, violation.Violations)
+	}
+	for path, keyword := range want {
+		if got[path] != keyword {
+			t.Errorf("expected %s violation at %s, got %q", keyword, path, got[path])
+		}
+	}
+}
+
+func TestUnifiedResponse(t *testing.T) {
+	body := `{
+		"id": "chatcmpl-7", "object": "chat.completion", "created": 1700000000,
+		"model": "gpt-4o-2024-08-06", "system_fingerprint": "fp_1",
+		"choices": [
+			{"index": 0, "finish_reason": "stop",
+			 "message": {"role": "assistant", "content": "{\"location\":\"Tokyo\",\"temperature\":22,\"unit\":\"C\",\"conditions\":\"Clear\"}",
+			   "refusal": null,
+			   "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"location\":\"Tokyo\"}"}}]},
+			 "logprobs": {"content": [{"token": "{\"", "logprob": -0.01, "bytes": [123, 34],
+			   "top_logprobs": [{"token": "{\"", "logprob": -0.01, "bytes": [123, 34]}, {"token": "{", "logprob": -4.6, "bytes": [123]}]}]}},
+			{"index": 1, "finish_reason": "stop",
+			 "message": {"role": "assistant", "content": null, "refusal": "I can't help with that."}}
+		],
+		"usage": {"prompt_tokens": 20, "completion_tokens": 15, "total_tokens": 35}
+	}`
+	var resp utils.APIResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 同じ値を ChatCompletion としても扱える
+	var completion *utils.ChatCompletion = &resp
+
+	if completion.ID != "chatcmpl-7" || completion.Model != "gpt-4o-2024-08-06" || completion.SystemFingerprint != "fp_1" || completion.Usage.TotalTokens != 35 {
+		t.Errorf("unexpected metadata: %+v", completion)
+	}
+	weather, err := utils.HandleResponse[schema.WeatherResponse](completion)
+	if err != nil || weather.Location != "Tokyo" || weather.Temperature != 22 {
+		t.Fatalf("unexpected structured result %+v: %v", weather, err)
+	}
+	call, err := completion.GetFunctionCall("get_weather")
+	if err != nil || call.ID != "call_1" {
+		t.Fatalf("unexpected function call %+v: %v", call, err)
+	}
+
+	logprobs := completion.Choices[0].LogProbs
+	if logprobs == nil || len(logprobs.Content) != 1 || len(logprobs.Content[0].TopLogProbs) != 2 || logprobs.Content[0].TopLogProbs[1].Token != "{" {
+		t.Errorf("unexpected logprobs: %+v", logprobs)
+	}
+
+	first, second := completion.Choices[0].Message, completion.Choices[1].Message
+	if first.Refusal != nil || second.Refusal == nil || *second.Refusal != "I can't help with that." {
+		t.Errorf("unexpected refusals: %v, %v", first.Refusal, second.Refusal)
+	}
+	if second.Text() != "" {
+		t.Errorf("expected empty text for null content, got %q", second.Text())
+	}
+	if got := first.ToMessage(); string(got.Content) != string(first.Content) || len(got.ToolCalls) != 1 {
+		t.Errorf("expected message to keep content and tool calls, got %+v", got)
+	}
+	if got := second.ToMessage(); got.Content != nil {
+		t.Errorf("expected null content to be omitted, got %s", got.Content)
+	}
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy はリクエスト失敗時の再試行方針を定義します
+type RetryPolicy struct {
+	// MaxAttempts は初回を含む最大試行回数です（1以下の場合は再試行しません）
+	MaxAttempts int
+	// InitialBackoff は最初の再試行までの待機時間です
+	InitialBackoff time.Duration
+	// MaxBackoff は待機時間の上限です
+	// サーバーが指定した待機時間がこれを超える場合は、再試行せずにエラーを返します
+	MaxBackoff time.Duration
+	// Multiplier は再試行ごとに待機時間へ掛ける係数です
+	Multiplier float64
+	// Jitter は待機時間をランダムに揺らす割合です（0〜1）
+	Jitter float64
+}
+
+// NewRetryPolicy はデフォルト値のRetryPolicyを作成します
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// maxAttempts はポリシーに従った最大試行回数を返します
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// backoff は attempt 回目の失敗後に待機する時間を計算します
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	if wait < 0 {
+		return 0
+	}
+	return time.Duration(wait)
+}
+
+// isRetryableStatus は再試行すべきステータスコードかを判定します
+func isRetryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// isRetryable はエラーが再試行可能かを判定します
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		// 利用枠の不足は再試行しても解消しない
+		if apiErr.Code == "insufficient_quota" {
+			return false
+		}
+		return isRetryableStatus(apiErr.StatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// allows はサーバーが指定した待機時間がポリシーの上限（MaxBackoff）以内かを判定します
+func (p *RetryPolicy) allows(wait time.Duration) bool {
+	return p.MaxBackoff <= 0 || wait <= p.MaxBackoff
+}
+
+// retryAfter はレスポンスヘッダーからサーバーが指定した待機時間を取得します
+// Retry-After を優先し、なければ残りが0になった制限の x-ratelimit-reset-* を使用します
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	if header == nil {
+		return 0, false
+	}
+
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return max(date.Sub(now), 0), true
+		}
+	}
+
+	var wait time.Duration
+	found := false
+	for _, limit := range []string{"requests", "tokens"} {
+		if header.Get("x-ratelimit-remaining-"+limit) != "0" {
+			continue
+		}
+		d, err := time.ParseDuration(header.Get("x-ratelimit-reset-" + limit))
+		if err != nil {
+			continue
+		}
+		found = true
+		wait = max(wait, d)
+	}
+	return wait, found
+}
+
+// sleepContext はコンテキストがキャンセルされるまでの間、指定時間待機します
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package utils_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// testCompletionBody は gpt-4o で 1000 トークン（うち 200 がキャッシュ）を入力し、500 トークン（うち 100 が推論）を出力したレスポンスです
+const testCompletionBody = `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o-2024-08-06",` +
+	`"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}],` +
+	`"usage":{"prompt_tokens":1000,"completion_tokens":500,"total_tokens":1500,` +
+	`"prompt_tokens_details":{"cached_tokens":200},"completion_tokens_details":{"reasoning_tokens":100}}}`
+
+// tryAgainBody は newFlakyServer が失敗時に返す汎用のエラーボディです
+const tryAgainBody = `{"error":{"message":"try again"}}`
+
+// newFlakyServer は最初の failures 回だけ statusCode と errorBody を返し、その後は成功するテスト用サーバーを作成します
+func newFlakyServer(t *testing.T, failures int32, statusCode int, header http.Header, errorBody string) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(statusCode)
+			w.Write([]byte(errorBody))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testCompletionBody))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newRetryClient(server *httptest.Server, maxAttempts int) *utils.Client {
+	config := utils.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	config.Retry = &utils.RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Multiplier:     2,
+	}
+	return utils.NewClient(config)
+}
+
+func TestRetryOnRateLimitWithRetryAfter(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"0"}}
+	server, calls := newFlakyServer(t, 2, http.StatusTooManyRequests, header, tryAgainBody)
+	client := newRetryClient(server, 3)
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	}
+	res, err := client.SendRequestWithFunctionCall(opts)
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if res.ID != "chatcmpl-1" {
+		t.Errorf("unexpected completion id: %s", res.ID)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestRetryWaitsFullRetryAfter(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"0.1"}}
+	server, calls := newFlakyServer(t, 1, http.StatusTooManyRequests, header, tryAgainBody)
+	config := utils.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	config.Retry = &utils.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Second}
+	client := utils.NewClient(config)
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	}
+	start := time.Now()
+	if _, err := client.SendRequestWithFunctionCall(opts); err != nil {
+		t.Fatalf("expected success after retry, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected to wait the full Retry-After, waited %v", elapsed)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestRetryGivesUpWhenRetryAfterExceedsMaxBackoff(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"60"}}
+	server, calls := newFlakyServer(t, 1, http.StatusTooManyRequests, header, tryAgainBody)
+	client := newRetryClient(server, 2)
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	}
+	start := time.Now()
+	_, err := client.SendRequestWithFunctionCall(opts)
+	var apiErr *utils.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the 429 APIError, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected to give up without waiting, waited %v", elapsed)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single attempt, got %d", got)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	header := http.Header{"X-Ratelimit-Reset-Requests": []string{"1ms"}}
+	server, calls := newFlakyServer(t, 10, http.StatusServiceUnavailable, header, tryAgainBody)
+	client := newRetryClient(server, 2)
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	}
+	if _, err := client.SendRequestWithFunctionCall(opts); err == nil {
+		t.Fatal("expected an error after exhausting retries")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	server, calls := newFlakyServer(t, 10, http.StatusBadRequest, nil, tryAgainBody)
+	client := newRetryClient(server, 3)
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	}
+	if _, err := client.SendRequestWithFunctionCall(opts); err == nil {
+		t.Fatal("expected an error for 400 response")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single attempt, got %d", got)
+	}
+}
@@ -0,0 +1,367 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ChatCompletionChunk はストリーミングで受信する差分チャンクを定義します
+type ChatCompletionChunk struct {
+	ID                string        `json:"id"`
+	Object            string        `json:"object"`
+	Created           int64         `json:"created"`
+	Model             string        `json:"model"`
+	SystemFingerprint string        `json:"system_fingerprint"`
+	Choices           []ChunkChoice `json:"choices"`
+	Usage             *Usage        `json:"usage,omitempty"`
+	// PromptFilterResults はAzure OpenAIのプロンプトに対するコンテンツフィルターの結果です
+	PromptFilterResults []PromptFilterResult `json:"prompt_filter_results,omitempty"`
+}
+
+// ChunkChoice はチャンク内の選択肢の差分です
+type ChunkChoice struct {
+	Index        int        `json:"index"`
+	Delta        ChunkDelta `json:"delta"`
+	FinishReason *string    `json:"finish_reason"`
+	LogProbs     *LogProbs  `json:"logprobs"`
+	// ContentFilterResults はAzure OpenAIのコンテンツフィルターの結果です
+	ContentFilterResults *ContentFilterResults `json:"content_filter_results,omitempty"`
+}
+
+// ChunkDelta はメッセージの差分です
+type ChunkDelta struct {
+	Role      string          `json:"role,omitempty"`
+	Content   *string         `json:"content,omitempty"`
+	Refusal   *string         `json:"refusal,omitempty"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// ToolCallDelta はToolCallの差分です
+// Function.Arguments は複数のチャンクに分割されて届きます
+type ToolCallDelta struct {
+	Index    int      `json:"index"`
+	ID       string   `json:"id,omitempty"`
+	Type     string   `json:"type,omitempty"`
+	Function Function `json:"function"`
+}
+
+// Stream はServer-Sent Eventsで届くチャンクを順に読み出します
+type Stream struct {
+	ctx    context.Context
+	resp   *http.Response
+	reader *bufio.Reader
+	acc    *completionAccumulator
+	err    error
+	// onUsage はトークン使用量を含むチャンクを受信したときに呼び出されます
+	onUsage func(model string, usage Usage)
+	// stripCodeFence は内容からMarkdownのコードブロックを取り除くかどうかです
+	stripCodeFence bool
+}
+
+func newStream(ctx context.Context, resp *http.Response) *Stream {
+	return &Stream{
+		ctx:    ctx,
+		resp:   resp,
+		reader: bufio.NewReader(resp.Body),
+		acc:    newCompletionAccumulator(),
+	}
+}
+
+// Recv は次のチャンクを返します
+// ストリームが終了した場合は io.EOF を返します
+func (s *Stream) Recv() (*ChatCompletionChunk, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	chunk, err := s.next()
+	if err != nil {
+		s.err = err
+		s.Close()
+		return nil, err
+	}
+
+	s.acc.add(chunk)
+	if chunk.Usage != nil && s.onUsage != nil {
+		s.onUsage(chunk.Model, *chunk.Usage)
+	}
+	return chunk, nil
+}
+
+// Chunks はチャンクを順に返すイテレータです
+// 正常終了時はエラーを返さずに終了します
+func (s *Stream) Chunks() iter.Seq2[*ChatCompletionChunk, error] {
+	return func(yield func(*ChatCompletionChunk, error) bool) {
+		for {
+			chunk, err := s.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(chunk, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Completion はこれまでに受信したチャンクから組み立てたChatCompletionを返します
+// ストリームを最後まで読み出した後に呼び出すと最終的なレスポンスが得られます
+func (s *Stream) Completion() *ChatCompletion {
+	completion := s.acc.completion()
+	if s.stripCodeFence {
+		cleanStructuredContent(completion)
+	}
+	return completion
+}
+
+// Close はストリームを閉じます
+func (s *Stream) Close() error {
+	return s.resp.Body.Close()
+}
+
+// next はSSEのイベントを1つ読み出してチャンクにデコードします
+func (s *Stream) next() (*ChatCompletionChunk, error) {
+	for {
+		data, err := s.readEvent()
+		if err != nil {
+			return nil, err
+		}
+		if data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			return nil, io.EOF
+		}
+
+		var streamErr struct {
+			Error *struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &streamErr); err == nil && streamErr.Error != nil {
+			return nil, fmt.Errorf("stream error: %s: %s", streamErr.Error.Type, streamErr.Error.Message)
+		}
+
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("error parsing stream chunk: %v", err)
+		}
+		return &chunk, nil
+	}
+}
+
+// readEvent は空行で区切られた1イベント分の data フィールドを読み出します
+func (s *Stream) readEvent() (string, error) {
+	var data []string
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", WrapRequestError(s.ctx, fmt.Errorf("error reading stream: %w", err))
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				return strings.Join(data, "\n"), nil
+			}
+		case strings.HasPrefix(line, ":"):
+			// コメント行は無視
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+
+		if errors.Is(err, io.EOF) {
+			if len(data) > 0 {
+				return strings.Join(data, "\n"), nil
+			}
+			return "", io.EOF
+		}
+	}
+}
+
+// completionAccumulator はチャンクを結合してChatCompletionを再構築します
+type completionAccumulator struct {
+	base    ChatCompletion
+	choices map[int]*choiceAccumulator
+}
+
+type choiceAccumulator struct {
+	role          string
+	content       strings.Builder
+	hasContent    bool
+	refusal       strings.Builder
+	hasRefusal    bool
+	finishReason  string
+	logProbs      *LogProbs
+	contentFilter *ContentFilterResults
+	toolCalls     map[int]*ToolCall
+}
+
+func newCompletionAccumulator() *completionAccumulator {
+	return &completionAccumulator{
+		base:    ChatCompletion{Object: "chat.completion"},
+		choices: make(map[int]*choiceAccumulator),
+	}
+}
+
+func (a *completionAccumulator) add(chunk *ChatCompletionChunk) {
+	if chunk.ID != "" {
+		a.base.ID = chunk.ID
+	}
+	if chunk.Model != "" {
+		a.base.Model = chunk.Model
+	}
+	if chunk.Created != 0 {
+		a.base.Created = chunk.Created
+	}
+	if chunk.SystemFingerprint != "" {
+		a.base.SystemFingerprint = chunk.SystemFingerprint
+	}
+	if chunk.Usage != nil {
+		a.base.Usage = *chunk.Usage
+	}
+	a.base.PromptFilterResults = append(a.base.PromptFilterResults, chunk.PromptFilterResults...)
+
+	for _, delta := range chunk.Choices {
+		choice, ok := a.choices[delta.Index]
+		if !ok {
+			choice = &choiceAccumulator{toolCalls: make(map[int]*ToolCall)}
+			a.choices[delta.Index] = choice
+		}
+		if delta.Delta.Role != "" {
+			choice.role = delta.Delta.Role
+		}
+		if delta.Delta.Content != nil {
+			choice.content.WriteString(*delta.Delta.Content)
+			choice.hasContent = true
+		}
+		if delta.Delta.Refusal != nil {
+			choice.refusal.WriteString(*delta.Delta.Refusal)
+			choice.hasRefusal = true
+		}
+		if delta.FinishReason != nil {
+			choice.finishReason = *delta.FinishReason
+		}
+		if delta.LogProbs != nil {
+			if choice.logProbs == nil {
+				choice.logProbs = &LogProbs{}
+			}
+			choice.logProbs.Content = append(choice.logProbs.Content, delta.LogProbs.Content...)
+			choice.logProbs.Refusal = append(choice.logProbs.Refusal, delta.LogProbs.Refusal...)
+		}
+		if delta.ContentFilterResults != nil {
+			choice.contentFilter = delta.ContentFilterResults
+		}
+		for _, tc := range delta.Delta.ToolCalls {
+			call, ok := choice.toolCalls[tc.Index]
+			if !ok {
+				call = &ToolCall{}
+				choice.toolCalls[tc.Index] = call
+			}
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			if tc.Type != "" {
+				call.Type = tc.Type
+			}
+			if tc.Function.Name != "" {
+				call.Function.Name += tc.Function.Name
+			}
+			call.Function.Arguments += tc.Function.Arguments
+		}
+	}
+}
+
+func (a *completionAccumulator) completion() *ChatCompletion {
+	completion := a.base
+	completion.Choices = make([]Choice, 0, len(a.choices))
+
+	for _, index := range sortedKeys(a.choices) {
+		acc := a.choices[index]
+		message := ChatMessage{Role: acc.role}
+		if acc.hasContent {
+			message.Content, _ = json.Marshal(acc.content.String())
+		}
+		if acc.hasRefusal {
+			refusal := acc.refusal.String()
+			message.Refusal = &refusal
+		}
+		for _, callIndex := range sortedKeys(acc.toolCalls) {
+			call := *acc.toolCalls[callIndex]
+			if call.Type == "" {
+				call.Type = "function"
+			}
+			message.ToolCalls = append(message.ToolCalls, call)
+		}
+
+		completion.Choices = append(completion.Choices, Choice{
+			FinishReason:         acc.finishReason,
+			Index:                index,
+			LogProbs:             acc.logProbs,
+			Message:              message,
+			ContentFilterResults: acc.contentFilter,
+		})
+	}
+
+	return &completion
+}
+
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// StreamRequestWithFunctionCall はFunction Callingのリクエストをストリーミングで送信します
+func (c *Client) StreamRequestWithFunctionCall(ctx context.Context, opts RequestOptions) (*Stream, error) {
+	if len(opts.Messages) == 0 {
+		return nil, fmt.Errorf("at least one message is required")
+	}
+	return c.stream(ctx, c.functionCallBody(opts))
+}
+
+// StreamRequestWithStructuredOutput は構造化出力のリクエストをストリーミングで送信します
+func (c *Client) StreamRequestWithStructuredOutput(ctx context.Context, opts RequestOptions) (*Stream, error) {
+	if len(opts.Messages) == 0 {
+		return nil, fmt.Errorf("at least one message is required")
+	}
+	mode := c.structuredMode.resolve(c.config.StructuredOutputMode)
+	stream, err := c.stream(ctx, c.structuredOutputBody(opts, mode))
+	if err != nil {
+		return nil, err
+	}
+	// json_object・prompt ではスキーマが強制されず、コードブロックで囲まれることがある
+	stream.stripCodeFence = mode != StructuredOutputJSONSchema
+	return stream, nil
+}
+
+// stream は stream: true を付与してリクエストを送信し、Streamを返します
+func (c *Client) stream(ctx context.Context, reqBody RequestBody) (*Stream, error) {
+	reqBody.Stream = true
+	reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+
+	resp, err := c.post(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	stream := newStream(ctx, resp)
+	if c.config.Costs != nil {
+		stream.onUsage = func(model string, usage Usage) {
+			c.config.CostSettings.Record(ctx, c.config.Model, model, usage)
+		}
+	}
+	return stream, nil
+}
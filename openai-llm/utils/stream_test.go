@@ -0,0 +1,124 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// newSSEServer は指定したイベントをServer-Sent Eventsとして返すテスト用サーバーを作成します
+func newSSEServer(t *testing.T, events []string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body utils.RequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if !body.Stream || body.StreamOptions == nil || !body.StreamOptions.IncludeUsage {
+			t.Errorf("expected stream with include_usage, got %+v", body)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			w.Write([]byte("data: " + event + "\n\n"))
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStreamContentDeltas(t *testing.T) {
+	server := newSSEServer(t, []string{
+		`{"id":"c1","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`,
+		`{"id":"c1","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"Hel"},"logprobs":{"content":[{"token":"Hel","logprob":-0.5,"bytes":[72,101,108],"top_logprobs":[]}]}}]}`,
+		`{"id":"c1","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"lo"},"logprobs":{"content":[{"token":"lo","logprob":-0.1,"bytes":[108,111],"top_logprobs":[]}]},"finish_reason":"stop"}]}`,
+		`{"id":"c1","model":"gpt-4o","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`,
+	})
+	client := newTestClient(server)
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+	}
+	stream, err := client.StreamRequestWithFunctionCall(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer stream.Close()
+
+	var text strings.Builder
+	for chunk, err := range stream.Chunks() {
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != nil {
+				text.WriteString(*choice.Delta.Content)
+			}
+		}
+	}
+	if text.String() != "Hello" {
+		t.Errorf("unexpected streamed text: %q", text.String())
+	}
+
+	completion := stream.Completion()
+	if completion.ID != "c1" || completion.Model != "gpt-4o" {
+		t.Errorf("unexpected completion metadata: %+v", completion)
+	}
+	if len(completion.Choices) != 1 || completion.Choices[0].Message.Text() != "Hello" {
+		t.Fatalf("unexpected completion choices: %+v", completion.Choices)
+	}
+	if completion.Choices[0].FinishReason != "stop" {
+		t.Errorf("unexpected finish reason: %s", completion.Choices[0].FinishReason)
+	}
+	if logprobs := completion.Choices[0].LogProbs; logprobs == nil || len(logprobs.Content) != 2 || logprobs.Content[1].Token != "lo" {
+		t.Errorf("expected logprobs of all chunks to be merged, got %+v", logprobs)
+	}
+	if completion.Usage.TotalTokens != 7 {
+		t.Errorf("expected usage to be recorded, got %+v", completion.Usage)
+	}
+}
+
+func TestStreamMergesToolCallFragments(t *testing.T) {
+	server := newSSEServer(t, []string{
+		`{"id":"c2","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"weather","arguments":""}}]}}]}`,
+		`{"id":"c2","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"weather","arguments":"{\"loca"}}]}}]}`,
+		`{"id":"c2","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"location\":\"Tokyo\"}"}}]}}]}`,
+		`{"id":"c2","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"tion\":\"Osaka\"}"}}]},"finish_reason":"tool_calls"}]}`,
+	})
+	client := newTestClient(server)
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "weather in Tokyo and Osaka?")},
+	}
+	stream, err := client.StreamRequestWithFunctionCall(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer stream.Close()
+
+	for _, err := range stream.Chunks() {
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+	}
+
+	calls, err := stream.Completion().GetAllFunctionCalls("weather")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(calls))
+	}
+	if calls[0].ID != "call_a" || calls[0].Function.Arguments != `{"location":"Tokyo"}` {
+		t.Errorf("unexpected first call: %+v", calls[0])
+	}
+	if calls[1].ID != "call_b" || calls[1].Function.Arguments != `{"location":"Osaka"}` {
+		t.Errorf("unexpected second call: %+v", calls[1])
+	}
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"strings"
+)
+
+// StructuredStream は構造化出力のストリームから、途中まで埋まった T のスナップショットを順に返します
+type StructuredStream[T any] struct {
+	stream *Stream
+	buf    strings.Builder
+	last   string
+}
+
+// StreamStructuredOutput は構造化出力のリクエストをストリーミングで送信し、StructuredStreamを返します
+func StreamStructuredOutput[T any](ctx context.Context, c *Client, opts RequestOptions) (*StructuredStream[T], error) {
+	stream, err := c.StreamRequestWithStructuredOutput(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &StructuredStream[T]{stream: stream}, nil
+}
+
+// Next は内容が更新されたスナップショットを返します
+// 配列の要素（ObjectItem など）は要素が完成した時点で追加されます
+// ストリームが終了した場合は io.EOF を返します
+func (s *StructuredStream[T]) Next() (*T, error) {
+	for {
+		chunk, err := s.stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Index == 0 && choice.Delta.Content != nil {
+				s.buf.WriteString(*choice.Delta.Content)
+			}
+		}
+
+		content := s.buf.String()
+		if s.stream.stripCodeFence {
+			content = stripPartialCodeFence(content)
+		}
+		partial, ok := completePartialJSON(content)
+		if !ok || partial == s.last {
+			continue
+		}
+
+		var snapshot T
+		if err := json.Unmarshal([]byte(partial), &snapshot); err != nil {
+			continue
+		}
+		s.last = partial
+		return &snapshot, nil
+	}
+}
+
+// Snapshots はスナップショットを順に返すイテレータです
+func (s *StructuredStream[T]) Snapshots() iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		for {
+			snapshot, err := s.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(snapshot, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Result はストリームを最後まで読み出し、HandleResponse と同じ厳密なパースを行った結果を返します
+// opts は HandleResponse にそのまま渡されます
+func (s *StructuredStream[T]) Result(opts ...HandleOption) (*T, error) {
+	for {
+		_, err := s.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return HandleResponse[T](s.stream.Completion(), opts...)
+}
+
+// Completion は受信済みのチャンクから組み立てたChatCompletionを返します
+func (s *StructuredStream[T]) Completion() *ChatCompletion {
+	return s.stream.Completion()
+}
+
+// Close はストリームを閉じます
+func (s *StructuredStream[T]) Close() error {
+	return s.stream.Close()
+}
@@ -0,0 +1,108 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+// contentEvents は content を size バイトずつに分割したチャンクのイベントを作成します
+func contentEvents(t *testing.T, content string, size int) []string {
+	var events []string
+	for start := 0; start < len(content); start += size {
+		end := min(start+size, len(content))
+		piece, err := json.Marshal(content[start:end])
+		if err != nil {
+			t.Fatalf("failed to marshal piece: %v", err)
+		}
+		events = append(events, `{"id":"s1","choices":[{"index":0,"delta":{"content":`+string(piece)+`}}]}`)
+	}
+	return append(events, `{"id":"s1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`)
+}
+
+func TestStreamStructuredOutputSnapshots(t *testing.T) {
+	content := `{"objects": [{"name": "Red Chair", "category": "furniture"}, {"name": "Coffee Cup", "category": "kitchen\"ware"}, {"name": "Lamp", "category": "lighting"}]}`
+	server := newSSEServer(t, contentEvents(t, content, 7))
+	client := newTestClient(server)
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "analyze")},
+		Schema:   json.RawMessage(`{}`),
+	}
+	stream, err := utils.StreamStructuredOutput[schema.ObjectAnalysisResponse](context.Background(), client, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer stream.Close()
+
+	var counts []int
+	for snapshot, err := range stream.Snapshots() {
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+		for i, obj := range snapshot.Objects {
+			if obj.Name == "" || obj.Category == "" {
+				t.Errorf("snapshot contains incomplete object %d: %+v", i, obj)
+			}
+		}
+		counts = append(counts, len(snapshot.Objects))
+	}
+
+	for i := 1; i < len(counts); i++ {
+		if counts[i] < counts[i-1] {
+			t.Errorf("snapshot object count decreased: %v", counts)
+		}
+	}
+	if len(counts) < 3 || counts[len(counts)-1] != 3 {
+		t.Errorf("expected snapshots growing to 3 objects, got %v", counts)
+	}
+
+	result, err := stream.Result()
+	if err != nil {
+		t.Fatalf("unexpected error from final parse: %v", err)
+	}
+	if len(result.Objects) != 3 || result.Objects[1].Category != `kitchen"ware` {
+		t.Errorf("unexpected final result: %+v", result)
+	}
+}
+
+func TestStreamStructuredOutputStripsCodeFence(t *testing.T) {
+	content := "```json\n{\"location\": \"Tokyo\", \"temperature\": 19, \"unit\": \"C\", \"conditions\": \"Rain\"}\n```"
+	server := newSSEServer(t, contentEvents(t, content, 5))
+	config := utils.NewClientConfig("test-key")
+	config.Endpoint = server.URL
+	config.StructuredOutputMode = utils.StructuredOutputJSONObject
+	client := utils.NewClient(config)
+
+	opts := utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "What's the weather like in Tokyo today?")},
+		Schema:   json.RawMessage(`{}`),
+	}
+	stream, err := utils.StreamStructuredOutput[schema.WeatherResponse](context.Background(), client, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer stream.Close()
+
+	var last *schema.WeatherResponse
+	for snapshot, err := range stream.Snapshots() {
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+		last = snapshot
+	}
+	if last == nil || last.Location != "Tokyo" || last.Conditions != "Rain" {
+		t.Errorf("unexpected last snapshot: %+v", last)
+	}
+
+	result, err := stream.Result()
+	if err != nil {
+		t.Fatalf("unexpected error from final parse: %v", err)
+	}
+	if result.Location != "Tokyo" || result.Temperature != 19 {
+		t.Errorf("unexpected final result: %+v", result)
+	}
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+)
+
+// ToolChoice はモデルがどのツールを呼び出すかを指定します
+type ToolChoice struct {
+	// Mode は "auto"、"none"、"required" のいずれかです（Function を指定した場合は無視されます）
+	Mode string
+	// Function は呼び出しを強制する関数の名前です
+	Function string
+}
+
+// ToolChoiceAuto はツールを呼び出すかどうかをモデルに任せます
+func ToolChoiceAuto() *ToolChoice {
+	return &ToolChoice{Mode: "auto"}
+}
+
+// ToolChoiceNone はツールを呼び出さずにメッセージを生成させます
+func ToolChoiceNone() *ToolChoice {
+	return &ToolChoice{Mode: "none"}
+}
+
+// ToolChoiceRequired はいずれかのツールの呼び出しを強制します
+func ToolChoiceRequired() *ToolChoice {
+	return &ToolChoice{Mode: "required"}
+}
+
+// ToolChoiceFunction は指定した関数の呼び出しを強制します
+func ToolChoiceFunction(name string) *ToolChoice {
+	return &ToolChoice{Function: name}
+}
+
+// MarshalJSON は Function が指定されている場合は関数指定のオブジェクトに、それ以外は文字列に変換します
+func (t ToolChoice) MarshalJSON() ([]byte, error) {
+	if t.Function != "" {
+		return json.Marshal(map[string]any{
+			"type":     "function",
+			"function": map[string]string{"name": t.Function},
+		})
+	}
+	return json.Marshal(t.Mode)
+}
+
+// UnmarshalJSON は文字列または関数指定のオブジェクトを読み込みます
+func (t *ToolChoice) UnmarshalJSON(data []byte) error {
+	var mode string
+	if err := json.Unmarshal(data, &mode); err == nil {
+		*t = ToolChoice{Mode: mode}
+		return nil
+	}
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(data, &named); err != nil {
+		return fmt.Errorf("invalid tool_choice: %v", err)
+	}
+	*t = ToolChoice{Function: named.Function.Name}
+	return nil
+}
+
+// Validate は Mode が既知の値であるかを検証します
+func (t *ToolChoice) Validate() error {
+	if t.Function == "" && !slices.Contains([]string{"auto", "none", "required"}, t.Mode) {
+		return fmt.Errorf("tool_choice must be auto, none, required or a function, got %q", t.Mode)
+	}
+	return nil
+}
+
+// ValidateTools は指定されたツールの組み合わせで ToolChoice が有効かを検証します
+// ツールがない場合や、強制する関数がツールに含まれていない場合はエラーを返します
+func (t *ToolChoice) ValidateTools(names []string) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("tool_choice requires tools")
+	}
+	if t.Function != "" && !slices.Contains(names, t.Function) {
+		return fmt.Errorf("tool_choice function %s is not in the tools", t.Function)
+	}
+	return nil
+}
+
+// toolNames は RequestOptions.Schema に指定されたツール定義から関数名を取り出します
+func toolNames(toolsJSON json.RawMessage) ([]string, error) {
+	var tools []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(toolsJSON, &tools); err != nil {
+		return nil, fmt.Errorf("error parsing tools: %v", err)
+	}
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		names = append(names, tool.Function.Name)
+	}
+	return names, nil
+}
+
+// CallFunction は指定した関数の呼び出しを強制し、その引数を DecodeArguments で型 T にデコードして返します
+// opts.Schema には name の関数を含むツール定義を指定し、引数はそのパラメーターで検証されます
+// エラーの場合でもレスポンスを受信していればChatCompletionを返します
+func CallFunction[T any](ctx context.Context, c *Client, opts RequestOptions, name string) (*T, *ChatCompletion, error) {
+	opts.Parameters = opts.Parameters.Merge(&Parameters{ToolChoice: ToolChoiceFunction(name)})
+
+	completion, err := c.SendRequestWithFunctionCallContext(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	call, err := completion.GetFunctionCall(name)
+	if err != nil {
+		return nil, completion, err
+	}
+
+	var tools []schema.Tool
+	if err := json.Unmarshal(opts.Schema, &tools); err != nil {
+		return nil, completion, fmt.Errorf("error parsing tools: %v", err)
+	}
+	var decodeOpts []DecodeOption
+	for _, tool := range tools {
+		if tool.Function.Name == name {
+			decodeOpts = append(decodeOpts, WithTool(tool))
+			break
+		}
+	}
+	args, err := DecodeArguments[T](call, decodeOpts...)
+	if err != nil {
+		return nil, completion, err
+	}
+	return args, completion, nil
+}
@@ -0,0 +1,108 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+func TestToolChoiceMarshal(t *testing.T) {
+	tests := []struct {
+		choice *utils.ToolChoice
+		want   string
+	}{
+		{utils.ToolChoiceAuto(), `"auto"`},
+		{utils.ToolChoiceNone(), `"none"`},
+		{utils.ToolChoiceRequired(), `"required"`},
+		{utils.ToolChoiceFunction("weather"), `{"function":{"name":"weather"},"type":"function"}`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.choice)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("expected %s, got %s", tt.want, data)
+		}
+	}
+}
+
+func TestCallFunction(t *testing.T) {
+	server, captured := newCaptureServer(t, `{
+		"id": "chatcmpl-fc",
+		"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": null,
+			"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "weather", "arguments": "{\"location\":\"Tokyo\"}"}}]}}],
+		"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+	}`)
+	client := newTestClient(server)
+
+	toolsJSON, _ := json.Marshal([]schema.Tool{*schema.NewWeatherFunctionCallSchema()})
+	opts := utils.RequestOptions{
+		Messages:   []utils.Message{utils.NewMessage(utils.RoleUser, "What's the weather in Tokyo?")},
+		Schema:     toolsJSON,
+		Parameters: &utils.Parameters{ParallelToolCalls: utils.Ptr(false)},
+	}
+	args, completion, err := utils.CallFunction[struct {
+		Location string `json:"location"`
+	}](context.Background(), client, opts, "weather")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if args.Location != "Tokyo" || completion.Usage.TotalTokens != 15 {
+		t.Errorf("unexpected result: %+v, %+v", args, completion.Usage)
+	}
+
+	body := *captured
+	choice, ok := body["tool_choice"].(map[string]any)
+	if !ok || choice["function"].(map[string]any)["name"] != "weather" {
+		t.Errorf("expected forced tool choice, got %v", body["tool_choice"])
+	}
+	if body["parallel_tool_calls"] != false {
+		t.Errorf("expected parallel_tool_calls to be kept, got %v", body["parallel_tool_calls"])
+	}
+}
+
+func TestCallFunctionValidatesAgainstTool(t *testing.T) {
+	server, _ := newCaptureServer(t, `{
+		"id": "chatcmpl-fc",
+		"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": null,
+			"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "weather", "arguments": "{\"location\":7}"}}]}}]
+	}`)
+
+	toolsJSON, _ := json.Marshal([]schema.Tool{*schema.NewWeatherFunctionCallSchema()})
+	_, completion, err := utils.CallFunction[schema.WeatherFunctionArgs](context.Background(), newTestClient(server), utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "What's the weather in Tokyo?")},
+		Schema:   toolsJSON,
+	}, "weather")
+	var argErr *utils.ArgumentError
+	if !errors.As(err, &argErr) || !slices.Equal(argErr.Mistyped, []string{"$.location"}) {
+		t.Errorf("expected location to be reported as mistyped, got %v", err)
+	}
+	if completion == nil {
+		t.Error("expected the completion to be returned with the error")
+	}
+}
+
+func TestCallFunctionUnknownTool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("invalid request should not be sent")
+	}))
+	t.Cleanup(server.Close)
+
+	toolsJSON, _ := json.Marshal([]schema.Tool{*schema.NewWeatherFunctionCallSchema()})
+	_, _, err := utils.CallFunction[map[string]any](context.Background(), newTestClient(server), utils.RequestOptions{
+		Messages: []utils.Message{utils.NewMessage(utils.RoleUser, "hello")},
+		Schema:   toolsJSON,
+	}, "forecast")
+	if err == nil || !strings.Contains(err.Error(), "forecast is not in the tools") {
+		t.Errorf("expected unknown function error, got %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/yuki5155/go-llms/openai-llm/schema"
+)
+
+// DefaultMaxToolIterations は ToolRunner がモデルへ問い合わせる回数のデフォルト上限です
+const DefaultMaxToolIterations = 10
+
+// ErrMaxIterations はツール実行ループが上限回数に達したことを示します
+var ErrMaxIterations = errors.New("tool runner reached the maximum number of iterations")
+
+// ToolHandler はツール呼び出しを処理する関数です
+// 戻り値が文字列の場合はそのまま、それ以外はJSONに変換してモデルに返します
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (any, error)
+
+type registeredTool struct {
+	definition schema.Tool
+	handler    ToolHandler
+}
+
+// ToolRegistry はツール名とハンドラーの対応を管理します
+type ToolRegistry struct {
+	tools map[string]registeredTool
+	order []string
+}
+
+// NewToolRegistry は空のToolRegistryを作成します
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]registeredTool)}
+}
+
+// Register はツール定義とハンドラーを登録します
+func (r *ToolRegistry) Register(tool schema.Tool, handler ToolHandler) error {
+	name := tool.Function.Name
+	if name == "" {
+		return fmt.Errorf("tool name is required")
+	}
+	if handler == nil {
+		return fmt.Errorf("handler for tool %s is nil", name)
+	}
+	if _, exists := r.tools[name]; exists {
+		return fmt.Errorf("tool %s is already registered", name)
+	}
+	if tool.Type == "" {
+		tool.Type = "function"
+	}
+
+	r.tools[name] = registeredTool{definition: tool, handler: handler}
+	r.order = append(r.order, name)
+	return nil
+}
+
+// RegisterFunc は引数の型 A からパラメータのスキーマを生成してツールを登録します
+// ハンドラーにはJSONの引数を修復・検証してデコードした A が渡されます
+// 引数がスキーマに従っていない場合はハンドラーを呼び出さず、不足や型の誤りをモデルに返します
+func RegisterFunc[A any](r *ToolRegistry, name, description string, fn func(ctx context.Context, args A) (any, error)) error {
+	parameters, err := schema.From[A]()
+	if err != nil {
+		return fmt.Errorf("error generating parameters for tool %s: %w", name, err)
+	}
+
+	tool := schema.Tool{
+		Type: "function",
+		Function: schema.Function{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+			Strict:      true,
+		},
+	}
+	return r.Register(tool, func(ctx context.Context, arguments json.RawMessage) (any, error) {
+		call := &ToolCall{Function: Function{Name: name, Arguments: string(arguments)}}
+		args, err := DecodeArguments[A](call, WithArgumentSchema(parameters), WithLenient())
+		if err != nil {
+			return nil, err
+		}
+		return fn(ctx, *args)
+	})
+}
+
+// Tools は登録順にツール定義を返します
+func (r *ToolRegistry) Tools() []schema.Tool {
+	tools := make([]schema.Tool, 0, len(r.order))
+	for _, name := range r.order {
+		tools = append(tools, r.tools[name].definition)
+	}
+	return tools
+}
+
+// MarshalTools は RequestOptions.Schema に指定できる形式でツール定義をJSONに変換します
+func (r *ToolRegistry) MarshalTools() (json.RawMessage, error) {
+	toolsJSON, err := json.Marshal(r.Tools())
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling tools: %v", err)
+	}
+	return toolsJSON, nil
+}
+
+// Call はToolCallに対応するハンドラーを実行し、モデルに返す文字列を返します
+// ハンドラーがパニックした場合は、プロセスを停止させずにその呼び出しのエラーとして返します
+func (r *ToolRegistry) Call(ctx context.Context, call ToolCall) (content string, err error) {
+	tool, ok := r.tools[call.Function.Name]
+	if !ok {
+		return "", fmt.Errorf("tool %s is not registered", call.Function.Name)
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			content, err = "", fmt.Errorf("tool %s panicked: %v", call.Function.Name, recovered)
+		}
+	}()
+
+	arguments := json.RawMessage(call.Function.Arguments)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	result, err := tool.handler(ctx, arguments)
+	if err != nil {
+		return "", err
+	}
+
+	if text, ok := result.(string); ok {
+		return text, nil
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling result of tool %s: %v", call.Function.Name, err)
+	}
+	return string(resultJSON), nil
+}
+
+// ToolRunner はモデルが要求したツールを実行し、結果を返してモデルが応答を終えるまで問い合わせを繰り返します
+type ToolRunner struct {
+	Client   *Client
+	Registry *ToolRegistry
+	// MaxIterations はモデルへ問い合わせる最大回数です
+	MaxIterations int
+	// Parallel が true の場合、1回の応答に含まれる複数のツール呼び出しを並行して実行します
+	Parallel bool
+	// Parameters は全ての問い合わせに適用する生成パラメーターです
+	Parameters *Parameters
+	// FinalParameters は上限回数の最後の問い合わせで Parameters に上書きする生成パラメーターです
+	// ToolChoice に ToolChoiceNone() を指定すると、ツールを呼ばずに回答させることができます
+	FinalParameters *Parameters
+}
+
+// NewToolRunner はデフォルト設定のToolRunnerを作成します
+func NewToolRunner(client *Client, registry *ToolRegistry) *ToolRunner {
+	return &ToolRunner{
+		Client:        client,
+		Registry:      registry,
+		MaxIterations: DefaultMaxToolIterations,
+		Parallel:      true,
+	}
+}
+
+// RunResult はツール実行ループの結果です
+type RunResult struct {
+	// Completion は最後に受信したレスポンスです
+	Completion *ChatCompletion
+	// Messages は入力メッセージに、アシスタントの応答とツールの結果を追加した履歴です
+	Messages []Message
+	// Iterations はモデルへ問い合わせた回数です
+	Iterations int
+	// Usage は全ての問い合わせのトークン使用量の合計です
+	Usage Usage
+}
+
+// Run はメッセージを送信し、モデルがツールを要求しなくなるまでツールの実行と問い合わせを繰り返します
+// 上限回数に達した場合は途中までの結果とともに ErrMaxIterations を返します
+func (r *ToolRunner) Run(ctx context.Context, messages []Message) (*RunResult, error) {
+	toolsJSON, err := r.Registry.MarshalTools()
+	if err != nil {
+		return nil, err
+	}
+
+	maxIterations := r.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxToolIterations
+	}
+
+	result := &RunResult{Messages: append([]Message(nil), messages...)}
+	for result.Iterations < maxIterations {
+		params := r.Parameters
+		if result.Iterations == maxIterations-1 && r.FinalParameters != nil {
+			params = r.Parameters.Merge(r.FinalParameters)
+		}
+		completion, err := r.Client.SendRequestWithFunctionCallContext(ctx, RequestOptions{
+			Messages:   result.Messages,
+			Schema:     toolsJSON,
+			Parameters: params,
+		})
+		if err != nil {
+			return result, err
+		}
+		result.Iterations++
+		result.Completion = completion
+		result.Usage = result.Usage.Add(completion.Usage)
+
+		if len(completion.Choices) == 0 {
+			return result, fmt.Errorf("no choices available")
+		}
+		reply := completion.Choices[0].Message
+		result.Messages = append(result.Messages, reply.ToMessage())
+		if len(reply.ToolCalls) == 0 {
+			return result, nil
+		}
+
+		result.Messages = append(result.Messages, r.execute(ctx, reply.ToolCalls)...)
+	}
+
+	return result, ErrMaxIterations
+}
+
+// execute はツール呼び出しを実行し、結果をツールメッセージとして返します
+// ハンドラーのエラーはモデルが対処できるようにエラー内容を結果として返します
+func (r *ToolRunner) execute(ctx context.Context, calls []ToolCall) []Message {
+	results := make([]Message, len(calls))
+	run := func(i int) {
+		content, err := r.Registry.Call(ctx, calls[i])
+		if err != nil {
+			errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
+			content = string(errorJSON)
+		}
+		results[i] = NewToolMessage(calls[i].ID, content)
+	}
+
+	if !r.Parallel || len(calls) == 1 {
+		for i := range calls {
+			run(i)
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+	for i := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(i)
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,200 @@
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yuki5155/go-llms/openai-llm/utils"
+)
+
+type weatherArgs struct {
+	Location string `json:"location" jsonschema:"description=Location for weather information"`
+}
+
+func newWeatherRegistry(t *testing.T) *utils.ToolRegistry {
+	registry := utils.NewToolRegistry()
+	err := utils.RegisterFunc(registry, "weather", "Get weather information",
+		func(ctx context.Context, args weatherArgs) (any, error) {
+			if args.Location == "Nowhere" {
+				return nil, fmt.Errorf("unknown location")
+			}
+			return map[string]any{"location": args.Location, "temperature": 20}, nil
+		})
+	if err != nil {
+		t.Fatalf("failed to register tool: %v", err)
+	}
+	return registry
+}
+
+func TestToolRunnerExecutesParallelCalls(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body utils.RequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+
+		switch calls.Add(1) {
+		case 1:
+			var tools []map[string]any
+			if err := json.Unmarshal(body.Tools, &tools); err != nil || len(tools) != 1 {
+				t.Errorf("expected one tool definition, got %s", body.Tools)
+			}
+			w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":null,"tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"location\":\"Tokyo\"}"}},
+				{"id":"call_2","type":"function","function":{"name":"weather","arguments":"{\"location\":\"Nowhere\"}"}}
+			]}}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`))
+		case 2:
+			if len(body.Messages) != 4 {
+				t.Errorf("expected 4 messages, got %d", len(body.Messages))
+				return
+			}
+			if got := body.Messages[1]; got.Role != utils.RoleAssistant || len(got.ToolCalls) != 2 {
+				t.Errorf("unexpected assistant message: %+v", got)
+			}
+			tokyo, nowhere := body.Messages[2], body.Messages[3]
+			if tokyo.Role != utils.RoleTool || tokyo.ToolCallID != "call_1" {
+				t.Errorf("unexpected tool message: %+v", tokyo)
+			}
+			var content string
+			json.Unmarshal(nowhere.Content, &content)
+			if nowhere.ToolCallID != "call_2" || content != `{"error":"unknown location"}` {
+				t.Errorf("unexpected error tool message: %+v (%s)", nowhere, content)
+			}
+			w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"It is 20 degrees in Tokyo."}}],"usage":{"prompt_tokens":30,"completion_tokens":8,"total_tokens":38}}`))
+		default:
+			t.Errorf("unexpected extra request")
+		}
+	}))
+	defer server.Close()
+
+	runner := utils.NewToolRunner(newTestClient(server), newWeatherRegistry(t))
+	result, err := runner.Run(context.Background(), []utils.Message{
+		utils.NewMessage(utils.RoleUser, "Weather in Tokyo and Nowhere?"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Iterations != 2 || len(result.Messages) != 5 {
+		t.Errorf("unexpected result: iterations=%d messages=%d", result.Iterations, len(result.Messages))
+	}
+	if result.Usage.TotalTokens != 53 {
+		t.Errorf("expected summed usage, got %+v", result.Usage)
+	}
+	if got := result.Completion.GetMessages()[0].Text(); got != "It is 20 degrees in Tokyo." {
+		t.Errorf("unexpected final content: %v", got)
+	}
+}
+
+func TestToolRunnerMaxIterations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[
+			{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"location\":\"Tokyo\"}"}}
+		]}}]}`))
+	}))
+	defer server.Close()
+
+	runner := utils.NewToolRunner(newTestClient(server), newWeatherRegistry(t))
+	runner.MaxIterations = 3
+	result, err := runner.Run(context.Background(), []utils.Message{
+		utils.NewMessage(utils.RoleUser, "Weather in Tokyo?"),
+	})
+	if !errors.Is(err, utils.ErrMaxIterations) {
+		t.Fatalf("expected ErrMaxIterations, got %v", err)
+	}
+	if result.Iterations != 3 {
+		t.Errorf("expected 3 iterations, got %d", result.Iterations)
+	}
+}
+
+func TestToolRunnerParameters(t *testing.T) {
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		requests = append(requests, body)
+		if len(requests) == 1 {
+			w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"location\":\"Tokyo\"}"}}
+			]}}]}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"It is 20 degrees in Tokyo."}}]}`))
+	}))
+	defer server.Close()
+
+	temperature := 0.2
+	runner := utils.NewToolRunner(newTestClient(server), newWeatherRegistry(t))
+	runner.MaxIterations = 2
+	runner.Parameters = &utils.Parameters{Temperature: &temperature}
+	runner.FinalParameters = &utils.Parameters{ToolChoice: utils.ToolChoiceNone()}
+	if _, err := runner.Run(context.Background(), []utils.Message{
+		utils.NewMessage(utils.RoleUser, "Weather in Tokyo?"),
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	for i, body := range requests {
+		if body["temperature"] != 0.2 {
+			t.Errorf("request %d: expected temperature 0.2, got %v", i, body["temperature"])
+		}
+	}
+	if choice, ok := requests[0]["tool_choice"]; ok {
+		t.Errorf("expected no tool_choice before the final turn, got %v", choice)
+	}
+	if requests[1]["tool_choice"] != "none" {
+		t.Errorf("expected tool_choice none on the final turn, got %v", requests[1]["tool_choice"])
+	}
+}
+
+func TestToolRunnerRecoversHandlerPanic(t *testing.T) {
+	registry := newWeatherRegistry(t)
+	err := utils.RegisterFunc(registry, "explode", "Always panics",
+		func(ctx context.Context, args struct{}) (any, error) {
+			panic("boom")
+		})
+	if err != nil {
+		t.Fatalf("failed to register tool: %v", err)
+	}
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"explode","arguments":"{}"}},
+				{"id":"call_2","type":"function","function":{"name":"weather","arguments":"{\"location\":\"Tokyo\"}"}}
+			]}}]}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"done"}}]}`))
+	}))
+	defer server.Close()
+
+	result, err := utils.NewToolRunner(newTestClient(server), registry).Run(context.Background(), []utils.Message{
+		utils.NewMessage(utils.RoleUser, "Weather in Tokyo?"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var exploded, weather string
+	json.Unmarshal(result.Messages[2].Content, &exploded)
+	json.Unmarshal(result.Messages[3].Content, &weather)
+	if exploded != `{"error":"tool explode panicked: boom"}` {
+		t.Errorf("expected the panic as the call's error result, got %s", exploded)
+	}
+	if !strings.Contains(weather, `"temperature":20`) {
+		t.Errorf("expected the other call to succeed, got %s", weather)
+	}
+}
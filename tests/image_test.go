@@ -76,7 +76,7 @@ func TestImageAnalyze(t *testing.T) {
 		return
 	}
 
-	fmt.Println(res.GetMessages()[0].Content)
+	fmt.Println(res.GetMessages()[0].Text())
 
 }
 